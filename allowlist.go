@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Allowlist decides which captured values must never be redacted.
+type Allowlist struct {
+	values  map[string]bool
+	regexes []*regexp.Regexp
+}
+
+// NewAllowlist compiles one or more allowlist configurations into a single Allowlist.
+// Literal values are compared case-insensitively, patterns must match the whole value.
+//
+// Parameters:
+//   - cfgs (...*AllowlistConfig): The allowlist configurations, nil entries are ignored.
+//
+// Returns:
+//   - *Allowlist: The merged allowlist.
+//   - error: An error if one of the patterns is not a valid regex.
+func NewAllowlist(cfgs ...*AllowlistConfig) (*Allowlist, error) {
+	al := &Allowlist{values: map[string]bool{}}
+
+	for _, cfg := range cfgs {
+		if cfg == nil {
+			continue
+		}
+
+		for _, value := range cfg.Values {
+			al.values[strings.ToLower(value)] = true
+		}
+
+		for _, pattern := range cfg.Patterns {
+			rex, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist pattern %q: %w", pattern, err)
+			}
+			al.regexes = append(al.regexes, rex)
+		}
+	}
+
+	return al, nil
+}
+
+// Allowed reports whether value is allowlisted.
+//
+// Parameters:
+//   - value (string): The captured value.
+//
+// Returns:
+//   - bool: true if the value must be kept.
+func (al *Allowlist) Allowed(value string) bool {
+	if al == nil {
+		return false
+	}
+
+	if al.values[strings.ToLower(value)] {
+		return true
+	}
+
+	for _, rex := range al.regexes {
+		if rex.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
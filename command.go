@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -36,6 +40,93 @@ var (
 		Action:  listKinds,
 	}
 
+	ShowConfig = &cli.Command{
+		Name:    "showConfig",
+		Usage:   `log-anonymizer showConfig`,
+		Aliases: []string{"sc"},
+		Action:  showConfig,
+	}
+
+	TestPattern = &cli.Command{
+		Name:      "testPattern",
+		Usage:     `log-anonymizer testPattern --kind engine --index 3 "SearchError User : jdoe Duration 12"`,
+		ArgsUsage: "[sample line...]",
+		Flags: []cli.Flag{
+			Kind,
+			Regex,
+			Index,
+			SampleFile,
+		},
+		Action: testPattern,
+	}
+
+	Explain = &cli.Command{
+		Name:      "explain",
+		Usage:     `log-anonymizer explain --file ./service.log --line 42`,
+		ArgsUsage: "[line]",
+		Flags: []cli.Flag{
+			Kind,
+			LogFile,
+			LineNumber,
+			SniffLines,
+		},
+		Action: explain,
+	}
+
+	CoverageReport = &cli.Command{
+		Name:  "coverage",
+		Usage: `log-anonymizer coverage --path ./logs`,
+		Flags: []cli.Flag{
+			Kind,
+			Path,
+			WorkerCount,
+			SniffLines,
+			Top,
+		},
+		Action: coverage,
+	}
+
+	SuggestPattern = &cli.Command{
+		Name:      "suggestPattern",
+		Usage:     `log-anonymizer suggestPattern --corpus ./logs "Principals for {{user:jdoe}}<123>"`,
+		ArgsUsage: "<sample line with {{marked}} values>",
+		Flags: []cli.Flag{
+			Kind,
+			Corpus,
+			Top,
+		},
+		Action: suggestPattern,
+	}
+
+	SelfTest = &cli.Command{
+		Name:  "selftest",
+		Usage: `log-anonymizer selftest --dir testdata/golden (axcVersion and kind come from the directories, kinds below <axcVersion>/detect/<log file name> are detected)`,
+		Flags: []cli.Flag{
+			GoldenDir,
+			Update,
+			Propagate,
+			TwoPass,
+			Verify,
+		},
+		Action: selfTest,
+	}
+
+	InitConfig = &cli.Command{
+		Name:  "initConfig",
+		Usage: `log-anonymizer initConfig --file config.yaml`,
+		Flags: []cli.Flag{
+			ConfigFile,
+			Force,
+		},
+		Action: initConfig,
+	}
+
+	Schema = &cli.Command{
+		Name:   "schema",
+		Usage:  `log-anonymizer schema > log-anonymizer.schema.json`,
+		Action: schema,
+	}
+
 	CleanUp = &cli.Command{
 		Name:    "cleanUp",
 		Usage:   `log-anonymizer cleanUp`,
@@ -53,6 +144,15 @@ var (
 			Kind,
 			Path,
 			WorkerCount,
+			Propagate,
+			TwoPass,
+			OutputDir,
+			KeepNames,
+			NoOverwrite,
+			ShutdownTimeout,
+			Verify,
+			UnknownFiles,
+			SniffLines,
 		},
 		Action: run,
 	}
@@ -63,10 +163,79 @@ var (
 		ListRegexPatterns,
 		ListKinds,
 		Run,
+		ShowConfig,
+		Schema,
+		InitConfig,
+		TestPattern,
+		Explain,
+		CoverageReport,
+		SuggestPattern,
+		SelfTest,
 	}
 )
 
 var (
+	ConfigFile = &cli.StringFlag{
+		Name:  "file",
+		Usage: "file the built-in configuration is written to",
+		Value: DEFAULT_CONFIG,
+	}
+
+	Force = &cli.BoolFlag{
+		Name:  "force",
+		Usage: "overwrite an existing file",
+		Value: false,
+	}
+
+	Regex = &cli.StringFlag{
+		Name:  "regex",
+		Usage: "regex to test instead of a configured pattern",
+	}
+
+	Index = &cli.IntFlag{
+		Name:  "index",
+		Usage: "number of the configured pattern of --kind to test, as printed by listRegexPatterns",
+		Value: 1,
+	}
+
+	SampleFile = &cli.StringFlag{
+		Name:  "file",
+		Usage: "file of sample lines, read instead of stdin when no sample lines are given",
+	}
+
+	LogFile = &cli.StringFlag{
+		Name:  "file",
+		Usage: "log file containing the line to explain, its kind and axcVersion are detected unless given",
+	}
+
+	LineNumber = &cli.IntFlag{
+		Name:  "line",
+		Usage: "number of the line of --file to explain",
+	}
+
+	Top = &cli.IntFlag{
+		Name:  "top",
+		Usage: "number of unmatched lines with user-like tokens to show per kind",
+		Value: DEFAULT_COVERAGE_TOP,
+	}
+
+	Corpus = &cli.StringFlag{
+		Name:  "corpus",
+		Usage: "log file or folder the suggested regex is tested against",
+	}
+
+	GoldenDir = &cli.StringFlag{
+		Name:  "dir",
+		Usage: "folder of <axcVersion>/<kind>/input.log and expected.log pairs",
+		Value: DEFAULT_GOLDEN_DIR,
+	}
+
+	Update = &cli.BoolFlag{
+		Name:  "update",
+		Usage: "write the actual output to expected.log instead of comparing",
+		Value: false,
+	}
+
 	Kind = &cli.StringFlag{
 		Name:  "kind",
 		Usage: "log file type e.g., engine",
@@ -84,6 +253,74 @@ var (
 		Usage: "number of workers",
 		Value: 2,
 	}
+
+	Propagate = &cli.StringFlag{
+		Name:  "propagate",
+		Usage: "redact captured values everywhere: off, file or run",
+		Value: PROPAGATE_OFF,
+		Action: func(c *cli.Context, v string) error {
+			switch v {
+			case PROPAGATE_OFF, PROPAGATE_FILE, PROPAGATE_RUN:
+				return nil
+			}
+			return fmt.Errorf("invalid propagate value %q, expected off, file or run", v)
+		},
+	}
+
+	TwoPass = &cli.BoolFlag{
+		Name:  "twoPass",
+		Usage: "learn sensitive values from all files before rewriting any of them",
+		Value: false,
+	}
+
+	OutputDir = &cli.StringFlag{
+		Name:    "outputDir",
+		Aliases: []string{"output-dir"},
+		Usage:   "write anonymized files to this folder, mirroring the layout under --path",
+	}
+
+	KeepNames = &cli.BoolFlag{
+		Name:  "keepNames",
+		Usage: "keep original file names in the output folder",
+		Value: false,
+	}
+
+	NoOverwrite = &cli.BoolFlag{
+		Name:  "noOverwrite",
+		Usage: "refuse to overwrite existing output files",
+		Value: false,
+	}
+
+	ShutdownTimeout = &cli.DurationFlag{
+		Name:  "shutdownTimeout",
+		Usage: "time files in progress may take to finish after an interrupt, a second interrupt aborts them at once",
+		Value: DEFAULT_SHUTDOWN_TIMEOUT,
+	}
+
+	Verify = &cli.BoolFlag{
+		Name:  "verify",
+		Usage: "check anonymized files for residual sensitive values",
+		Value: false,
+	}
+
+	UnknownFiles = &cli.StringFlag{
+		Name:  "unknownFiles",
+		Usage: "policy for files of unknown kind: skip, fail, generic or quarantine (requires --outputDir)",
+		Value: UNKNOWN_SKIP,
+		Action: func(c *cli.Context, v string) error {
+			switch v {
+			case UNKNOWN_SKIP, UNKNOWN_FAIL, UNKNOWN_GENERIC, UNKNOWN_QUARANTINE:
+				return nil
+			}
+			return fmt.Errorf("invalid unknownFiles value %q, expected skip, fail, generic or quarantine", v)
+		},
+	}
+
+	SniffLines = &cli.IntFlag{
+		Name:  "sniffLines",
+		Usage: "number of leading lines matched against content signatures, 0 to disable",
+		Value: DEFAULT_SNIFF_LINES,
+	}
 )
 
 /**
@@ -113,13 +350,13 @@ func listNamingPatterns(c *cli.Context) error {
 /**
  * listRegexPatterns is a function that takes a cli.Context object as input and returns an error.
  * It retrieves regex patterns from a global configuration based on the value of the "kind" flag provided in the context.
- * It then iterates over the retrieved patterns and prints them out with their corresponding index, kind, and pattern.
+ * It then iterates over the retrieved patterns and prints them out with their corresponding index, kind, library name, and pattern.
  *
  * Inputs:
  *     - c: A cli.Context object that contains the command-line context and flags.
  *
  * Outputs:
- *     - Prints out the index, kind, and pattern of each regex pattern.
+ *     - Prints out the index, kind, name (- for inline patterns), and pattern of each regex pattern.
  */
 func listRegexPatterns(c *cli.Context) error {
 	regexPatterns, err := GlobalConfig.GetRegexPatterns(c.String("kind"))
@@ -128,7 +365,11 @@ func listRegexPatterns(c *cli.Context) error {
 	}
 
 	for i, pattern := range regexPatterns {
-		fmt.Printf("%-4d%-16s%s\n", i+1, pattern.Kind, pattern.Pattern)
+		name := pattern.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Printf("%-4d%-16s%-28s%s\n", i+1, pattern.Kind, name, pattern.Pattern)
 	}
 
 	return nil
@@ -156,29 +397,292 @@ func listKinds(c *cli.Context) error {
 	return nil
 }
 
+// showConfig prints the effective configuration after layering all configuration
+// files and resolving extends, annotating each pattern with the file it came from.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while resolving or printing the configuration
+func showConfig(c *cli.Context) error {
+	node, err := GlobalConfiguration.EffectiveConfigNode()
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	defer enc.Close()
+
+	return enc.Encode(node)
+}
+
+// testPattern shows how a regex or a configured pattern matches sample lines
+// and how the lines are anonymized with it.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while selecting the pattern or reading the sample lines
+func testPattern(c *cli.Context) error {
+	pattern, err := selectPattern(GlobalConfig, c.String("regex"), c.String("kind"), c.Int("index"))
+	if err != nil {
+		return err
+	}
+
+	allowlist, err := NewAllowlist(GlobalConfig.Allowlist)
+	if c.String("kind") != DEFAULT_KIND {
+		allowlist, err = GlobalConfig.GetAllowlist(c.String("kind"))
+	}
+	if err != nil {
+		return err
+	}
+
+	lines, err := readSampleLines(c.Args().Slice(), c.String("file"), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	scheduler := NewScheduler().WithObfuscation(c.String("obfuscation"))
+	scheduler.TestPattern(os.Stdout, pattern, allowlist, lines)
+
+	return nil
+}
+
+// explain shows which rules anonymize a line of a log file, or a raw line of a given kind,
+// and how the line changes after each of them.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while reading the line or building the rules
+func explain(c *cli.Context) error {
+	scheduler := NewScheduler().
+		WithKind(c.String("kind")).
+		WithObfuscation(c.String("obfuscation")).
+		WithSniffLines(c.Int("sniffLines")).
+		WithAutoVersion(autoVersion(c))
+
+	if c.String("file") == "" {
+		if c.NArg() != 1 || c.String("kind") == DEFAULT_KIND {
+			return fmt.Errorf("explain needs --file and --line, or a line and its --kind")
+		}
+		_, err := scheduler.Explain(os.Stdout, GlobalConfig, c.String("kind"), c.Args().First())
+		return err
+	}
+
+	line, err := readLine(c.String("file"), c.Int("line"))
+	if err != nil {
+		return err
+	}
+
+	cfg, kind, err := scheduler.classifyLogFile(c.String("file"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-12s%s:%d\n", "file", c.String("file"), c.Int("line"))
+	_, err = scheduler.Explain(os.Stdout, cfg, kind, line)
+	return err
+}
+
+// coverage runs every configured pattern over the log files of a directory and reports
+// how often each one matched, dead patterns and frequent unmatched lines with user-like tokens.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while collecting the log files
+func coverage(c *cli.Context) error {
+	scheduler := NewScheduler().
+		WithPath(c.String("path")).
+		WithKind(c.String("kind")).
+		WithWorkerCount(c.Int("workerCount")).
+		WithSniffLines(c.Int("sniffLines")).
+		WithAutoVersion(autoVersion(c))
+
+	filePaths, err := scheduler.getLogs()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := scheduler.Cover(ctx, filePaths)
+	scheduler.coverage.Print(os.Stdout, c.Int("top"))
+
+	return aggregateResults(results, 0)
+}
+
+// suggestPattern turns a sample line with marked sensitive values into a regex,
+// tests it against a corpus and prints the configuration snippet.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while generalizing the line or reading the corpus
+func suggestPattern(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("suggestPattern needs a single sample line")
+	}
+
+	regex, values, err := SuggestRegex(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-12s%s\n", "regex", regex)
+	for i, marked := range values {
+		fmt.Printf("group %-6d%-12s%q\n", i+1, marked.group, marked.value)
+	}
+
+	if c.String("corpus") != "" {
+		if err := TestSuggestion(os.Stdout, regex, c.String("corpus"), c.Int("top")); err != nil {
+			return err
+		}
+	}
+
+	kind := c.String("kind")
+	if kind == DEFAULT_KIND {
+		kind = SUGGESTION_KIND
+	}
+	snippet, err := suggestionSnippet(kind, regex, suggestionCategories(values))
+	if err != nil {
+		return err
+	}
+	fmt.Print("\n" + snippet)
+
+	return nil
+}
+
+// selfTest anonymizes the input.log of every golden case with the loaded configuration
+// and the propagate, twoPass and verify flags of run, and compares the result with
+// its expected.log.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: An error carrying EXIT_SELFTEST_FAILURE if a case failed
+func selfTest(c *cli.Context) error {
+	newScheduler := func() *Scheduler {
+		return NewScheduler().
+			WithObfuscation(c.String("obfuscation")).
+			WithPropagate(c.String("propagate")).
+			WithTwoPass(c.Bool("twoPass")).
+			WithVerify(c.Bool("verify"))
+	}
+
+	failed, total, err := RunGoldenCases(c.Context, os.Stdout, GlobalConfiguration, c.String("dir"), newScheduler, c.Bool("update"))
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return withExitCode(EXIT_SELFTEST_FAILURE, fmt.Errorf("%d of %d golden cases failed", failed, total))
+	}
+	if c.Bool("update") {
+		fmt.Printf("%d golden cases updated\n", total)
+		return nil
+	}
+	fmt.Printf("%d golden cases passed\n", total)
+	return nil
+}
+
+// initConfig writes the built-in configuration to disk as a starting point for customisation.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while writing the file
+func initConfig(c *cli.Context) error {
+	if err := WriteEmbeddedConfig(c.String("file"), c.Bool("force")); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s\n", c.String("file"))
+	return nil
+}
+
+// schema prints the JSON Schema of the configuration file format.
+//
+// Parameters:
+//   - c: The CLI context
+//
+// Returns:
+//   - error: Any error encountered while printing the schema
+func schema(c *cli.Context) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(JSONSchema())
+}
+
+// autoVersion reports whether the axcVersion profile is detected per log file:
+// with --axcVersion auto or without --axcVersion, if versionDetection is configured.
+// An explicit --axcVersion, including default, pins the profile.
+func autoVersion(c *cli.Context) bool {
+	return (c.String("axcVersion") == AUTO_AXC_VERSION || !c.IsSet("axcVersion")) && GlobalConfiguration.hasVersionDetection()
+}
+
 /**
 * Run - Process files or folders based on command-line flags.
 * Inputs:
 *	c: A cli.Context object that contains the command-line context and flags.
 *
 * Outputs:
-*	err (error): A error that occurred during process.
+*	err (error): A error that occurred during process, carrying the exit code
+*	for partial or total failure and residual sensitive values.
  */
 func run(c *cli.Context) error {
+	if c.Bool("keepNames") && c.String("outputDir") == "" {
+		return fmt.Errorf("--keepNames requires --outputDir")
+	}
+	if c.String("unknownFiles") == UNKNOWN_QUARANTINE && c.String("outputDir") == "" {
+		return fmt.Errorf("--unknownFiles %s requires --outputDir", UNKNOWN_QUARANTINE)
+	}
+
 	scheduler := NewScheduler().
 		WithPath(c.String("path")).
 		WithKind(c.String("kind")).
 		WithWorkerCount(c.Int("workerCount")).
-		WithObfuscation(c.String("obfuscation"))
+		WithObfuscation(c.String("obfuscation")).
+		WithPropagate(c.String("propagate")).
+		WithTwoPass(c.Bool("twoPass")).
+		WithOutputDir(c.String("outputDir")).
+		WithKeepNames(c.Bool("keepNames")).
+		WithNoOverwrite(c.Bool("noOverwrite")).
+		WithShutdownTimeout(c.Duration("shutdownTimeout")).
+		WithVerify(c.Bool("verify")).
+		WithUnknownFiles(c.String("unknownFiles")).
+		WithSniffLines(c.Int("sniffLines")).
+		WithAutoVersion(autoVersion(c))
 
 	filePaths, err := scheduler.getLogs()
 	if err != nil {
 		return err
 	}
 
-	scheduler.Process(filePaths)
+	quarantined, err := scheduler.quarantine()
+	if err != nil {
+		return err
+	}
 
-	return nil
+	// stop dispatching files on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := append(scheduler.Process(ctx, filePaths), quarantined...)
+	scheduler.stats.Print(os.Stdout)
+	printResults(os.Stdout, results)
+
+	return aggregateResults(results, scheduler.stats.ResidualFiles())
 }
 
 // cleanUp deletes the anonymized log files after processing is complete.
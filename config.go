@@ -2,55 +2,117 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"regexp"
+	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/rs/zerolog/log"
 )
 
 type AnonymizerConfiguration struct {
-	AnonymizerConfigs []AnonymizerConfig `yaml:"anonymizer"`
+	Includes          []string                     `yaml:"include,omitempty"`    // configuration files this one is layered on
+	Patterns          map[string]PatternDefinition `yaml:"patterns,omitempty"`   // named regexes kinds refer to
+	Categories        map[string]string            `yaml:"categories,omitempty"` // capture group name -> entity category
+	AnonymizerConfigs []AnonymizerConfig           `yaml:"anonymizer"`
+
+	sources map[string]string // file each pattern was defined in, see patternSource
+	secrets []string          // values resolved from environment variables and files, see substituteReferences
+}
+
+const (
+	STRATEGY_MASK         = "mask"
+	STRATEGY_PSEUDONYMIZE = "pseudonymize"
+)
+
+// entity categories of replaced values that have no category of their own
+const (
+	CATEGORY_VALUE    = "value"    // unnamed capture group
+	CATEGORY_DENYLIST = "denylist" // denylist term
+	CATEGORY_LEARNED  = "learned"  // value learned from another line or file
+)
+
+// PatternDefinition is a named, reusable regex pattern.
+type PatternDefinition struct {
+	Regex       string   `yaml:"regex"`
+	Description string   `yaml:"description,omitempty"`
+	Groups      []string `yaml:"groups,omitempty"`   // names of the unnamed capture groups, in order
+	Strategy    string   `yaml:"strategy,omitempty"` // replacement of captured values: mask or pseudonymize, see Pattern
 }
 
 type AnonymizerConfig struct {
-	AxcVersion string      `yaml:"axcVersion"` // axcelerate version
-	LogConfigs []LogConfig `yaml:"logs"`
+	AxcVersion       string           `yaml:"axcVersion"`                 // axcelerate version
+	Extends          string           `yaml:"extends,omitempty"`          // axcVersion of the profile this one inherits from
+	VersionDetection []string         `yaml:"versionDetection,omitempty"` // regexes matching log header lines, a capture group extracts the version
+	Allowlist        *AllowlistConfig `yaml:"allowlist,omitempty"`        // values never redacted in any kind
+	Denylist         *DenylistConfig  `yaml:"denylist,omitempty"`         // terms redacted in every kind
+	LogConfigs       []LogConfig      `yaml:"logs"`
+
+	library    map[string]PatternDefinition // named patterns, set when the profile is resolved
+	categories map[string]string            // capture group name -> entity category, set when the profile is resolved
 }
 
 type LogConfig struct {
-	Kind           string   `yaml:"kind"`
-	NamingPatterns []string `yaml:"namingPatterns"`
-	RegexPatterns  []string `yaml:"regexPatterns"`
+	Kind              string           `yaml:"kind"`
+	NamingPatterns    []string         `yaml:"namingPatterns"`
+	RegexPatterns     []string         `yaml:"regexPatterns"`
+	Patterns          []string         `yaml:"patterns,omitempty"`          // names of library patterns used in addition to regexPatterns
+	ContentSignatures []string         `yaml:"contentSignatures,omitempty"` // regexes identifying the kind from the first lines of a log
+	Paths             *PathConfig      `yaml:"paths,omitempty"`             // path anonymization, disabled if omitted
+	Allowlist         *AllowlistConfig `yaml:"allowlist,omitempty"`
+	Denylist          *DenylistConfig  `yaml:"denylist,omitempty"`
+
+	// only meaningful in a profile that extends another one
+	Override             bool     `yaml:"override,omitempty"`             // replace the inherited kind instead of merging
+	Remove               bool     `yaml:"remove,omitempty"`               // drop the inherited kind
+	RemoveNamingPatterns []string `yaml:"removeNamingPatterns,omitempty"` // inherited naming patterns to drop
+	RemoveRegexPatterns  []string `yaml:"removeRegexPatterns,omitempty"`  // inherited regex patterns or pattern names to drop
+
+	inherited bool // taken over from the extended profile without being redefined, set when the profile is resolved
+}
+
+type DenylistConfig struct {
+	Files     []string `yaml:"files,omitempty"`     // term lists, plain text (one term per line) or CSV, relative to the configuration file
+	Terms     []string `yaml:"terms,omitempty"`     // inline terms
+	WholeWord bool     `yaml:"wholeWord,omitempty"` // only redact terms that are not part of a longer word
+	CSVHeader bool     `yaml:"csvHeader,omitempty"` // skip the first record of CSV term lists
+}
+
+type AllowlistConfig struct {
+	Values   []string `yaml:"values,omitempty"`   // literal values, compared case-insensitively
+	Patterns []string `yaml:"patterns,omitempty"` // regexes matched against the whole value
 }
 
-// LoadConfig loads a YAML configuration file and unmarshals it into an AnonymizerConfiguration struct.
+type PathConfig struct {
+	Segments           []string `yaml:"segments"`                     // segments to pseudonymize: user, server, share, case
+	CaseFolderPatterns []string `yaml:"caseFolderPatterns,omitempty"` // regexes identifying case or matter folders
+	KeepDirectories    []string `yaml:"keepDirectories,omitempty"`    // additional directory names never pseudonymized
+}
+
+// LoadConfig loads one or more YAML configuration files, including the files they include,
+// and layers them in order into an AnonymizerConfiguration struct.
 //
 // Inputs:
-// - path (string): The path to the YAML configuration file.
+// - paths (...string): The paths to the YAML configuration files, later files override earlier ones.
 //
 // Outputs:
 // - *AnonymizerConfiguration: A pointer to the loaded and unmarshaled AnonymizerConfiguration struct.
-// - error: An error indicating if there were any issues loading or unmarshaling the YAML configuration file.
-func LoadConfig(path string) (*AnonymizerConfiguration, error) {
-	var err error
+// - error: An error indicating if there were any issues loading or unmarshaling the YAML configuration files.
+func LoadConfig(paths ...string) (*AnonymizerConfiguration, error) {
 	var cfg = new(AnonymizerConfiguration)
 
-	yamlFile, err := os.ReadFile(path)
-	if err != nil {
-		fmt.Println("Error reading YAML file:", err)
-		return cfg, err
-	}
-
-	err = yaml.Unmarshal(yamlFile, cfg)
-	if err != nil {
-		fmt.Println("Error unmarshaling YAML:", err)
-		return cfg, err
+	for _, path := range paths {
+		layer, err := loadConfigFile(path, nil)
+		if err != nil {
+			return cfg, err
+		}
+		cfg = layerConfiguration(cfg, layer)
 	}
 
 	return cfg, nil
 }
 
 // GetAnonymizerConfigByAxcVersion searches for an AnonymizerConfig object in the AnonymizerConfigs slice based on the provided version parameter.
+// The version may match a profile exactly, by prefix or by range, see findAxcVersion.
+// Profiles that extend another profile are returned merged with it.
 //
 // Inputs:
 // - version (string): The version of the AnonymizerConfig to retrieve.
@@ -59,18 +121,53 @@ func LoadConfig(path string) (*AnonymizerConfiguration, error) {
 // - *AnonymizerConfig: A pointer to the matching AnonymizerConfig object if found, or nil if not found.
 // - error: An error indicating if there were any issues retrieving the AnonymizerConfig.
 func (cfg *AnonymizerConfiguration) GetAnonymizerConfigByAxcVersion(version string) (*AnonymizerConfig, error) {
+	axcVersion, err := cfg.findAxcVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.resolveAnonymizerConfig(axcVersion, nil)
+}
+
+// GetVersionDetection compiles the version detection regexes of the AnonymizerConfig.
+// Invalid regexes are skipped with a warning.
+//
+// Outputs:
+//   - []*regexp.Regexp: The compiled regexes, possibly empty.
+func (cfg *AnonymizerConfig) GetVersionDetection() []*regexp.Regexp {
+	var regexes []*regexp.Regexp
+
+	for _, pattern := range cfg.VersionDetection {
+		rex, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Warn().Msgf("invalid version detection %q of %s: %s", pattern, cfg.AxcVersion, err)
+			continue
+		}
+		regexes = append(regexes, rex)
+	}
+
+	return regexes
+}
+
+// hasVersionDetection reports whether any profile can be detected from log headers.
+func (cfg *AnonymizerConfiguration) hasVersionDetection() bool {
 	for _, anonymizerCfg := range cfg.AnonymizerConfigs {
-		if anonymizerCfg.AxcVersion == version {
-			return &anonymizerCfg, nil
+		if len(anonymizerCfg.VersionDetection) > 0 {
+			return true
 		}
 	}
-	return nil, fmt.Errorf("no config found for version %s", version)
+	return false
 }
 
 type Pattern struct {
-	Kind    string
-	Pattern string
-	Regex   *regexp.Regexp
+	Kind        string
+	Name        string // name in the pattern library, "" for inline regex patterns
+	Description string
+	Pattern     string
+	Regex       *regexp.Regexp
+	Groups      []string
+	Strategy    string   // mask or pseudonymize; patterns with named capture groups default to pseudonymize
+	Categories  []string // entity category of each capture group, "" if unnamed
 }
 
 // GetNamingPatterns retrieves a list of naming patterns based on the provided kind parameter.
@@ -105,6 +202,7 @@ func (cfg *AnonymizerConfig) GetNamingPatterns(kind string) ([]Pattern, error) {
 }
 
 // GetRegexPatterns retrieves a list of regex patterns based on the provided kind parameter.
+// Inline regex patterns come first, followed by the library patterns the kind refers to.
 //
 // Inputs:
 //   - kind (string): The kind of log for which to retrieve the regex patterns.
@@ -119,13 +217,42 @@ func (cfg *AnonymizerConfig) GetRegexPatterns(kind string) ([]Pattern, error) {
 		for _, pattern := range logCfg.RegexPatterns {
 			if kind == logCfg.Kind || kind == "*" {
 				rex, _ := regexp.Compile(pattern)
-				regexPatterns = append(regexPatterns, Pattern{
+				regexPatterns = append(regexPatterns, cfg.withCategories(Pattern{
 					Kind:    logCfg.Kind,
 					Pattern: pattern,
 					Regex:   rex,
-				})
+				}))
 			}
 		}
+
+		if kind != logCfg.Kind && kind != "*" {
+			continue
+		}
+
+		for _, name := range logCfg.Patterns {
+			definition, ok := cfg.library[name]
+			if !ok {
+				return regexPatterns, fmt.Errorf("unknown pattern %s in %s under %s", name, logCfg.Kind, cfg.AxcVersion)
+			}
+			rex, err := regexp.Compile(definition.Regex)
+			if err != nil {
+				return regexPatterns, fmt.Errorf("invalid pattern %s: %w", name, err)
+			}
+			switch definition.Strategy {
+			case "", STRATEGY_MASK, STRATEGY_PSEUDONYMIZE:
+			default:
+				return regexPatterns, fmt.Errorf("unknown strategy %q of pattern %s", definition.Strategy, name)
+			}
+			regexPatterns = append(regexPatterns, cfg.withCategories(Pattern{
+				Kind:        logCfg.Kind,
+				Name:        name,
+				Description: definition.Description,
+				Pattern:     definition.Regex,
+				Regex:       rex,
+				Groups:      definition.Groups,
+				Strategy:    definition.Strategy,
+			}))
+		}
 	}
 
 	if len(regexPatterns) == 0 {
@@ -135,6 +262,98 @@ func (cfg *AnonymizerConfig) GetRegexPatterns(kind string) ([]Pattern, error) {
 	return regexPatterns, nil
 }
 
+// category returns the entity category of capture group i, CATEGORY_VALUE if it has none.
+func (p Pattern) category(i int) string {
+	if i < len(p.Categories) && p.Categories[i] != "" {
+		return p.Categories[i]
+	}
+	return CATEGORY_VALUE
+}
+
+// withCategories sets the entity category of every capture group of pattern.
+// Named groups ((?P<email>...)) take precedence over the groups of a library
+// pattern, and the categories mapping of the configuration translates group
+// names into categories, e.g. mail -> email. Patterns with named groups are
+// pseudonymized unless their strategy says otherwise.
+func (cfg *AnonymizerConfig) withCategories(pattern Pattern) Pattern {
+	if pattern.Regex == nil {
+		return pattern
+	}
+
+	named := false
+	names := pattern.Regex.SubexpNames()[1:]
+	pattern.Categories = make([]string, len(names))
+	for i, name := range names {
+		if name != "" {
+			named = true
+		} else if i < len(pattern.Groups) {
+			name = pattern.Groups[i]
+		}
+		if category, ok := cfg.categories[name]; ok {
+			name = category
+		}
+		pattern.Categories[i] = strings.ToLower(name)
+	}
+
+	if pattern.Strategy == "" && named {
+		pattern.Strategy = STRATEGY_PSEUDONYMIZE
+	}
+
+	return pattern
+}
+
+// GetContentSignatures retrieves the content signatures based on the provided kind parameter.
+// Invalid regexes are skipped with a warning.
+//
+// Inputs:
+//   - kind (string): The kind of log for which to retrieve the content signatures, "*" for all.
+//
+// Outputs:
+//   - []Pattern: A slice of Pattern structs containing the content signatures, possibly empty.
+func (cfg *AnonymizerConfig) GetContentSignatures(kind string) []Pattern {
+	var signatures = []Pattern{}
+
+	for _, logCfg := range cfg.LogConfigs {
+		if kind != logCfg.Kind && kind != "*" {
+			continue
+		}
+		for _, pattern := range logCfg.ContentSignatures {
+			rex, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Warn().Msgf("invalid content signature %q of %s: %s", pattern, logCfg.Kind, err)
+				continue
+			}
+			signatures = append(signatures, Pattern{
+				Kind:    logCfg.Kind,
+				Pattern: pattern,
+				Regex:   rex,
+			})
+		}
+	}
+
+	return signatures
+}
+
+// kindsInOrder returns the kinds in configuration order, empty if there are none.
+func (cfg *AnonymizerConfig) kindsInOrder() []string {
+	kinds, _ := cfg.GetKinds()
+	return kinds
+}
+
+// ownKinds returns the kinds that the profile defines itself rather than inherits, in order.
+func (cfg *AnonymizerConfig) ownKinds(kinds []string) []string {
+	var own []string
+	for _, kind := range kinds {
+		for _, logCfg := range cfg.LogConfigs {
+			if logCfg.Kind == kind && !logCfg.inherited {
+				own = append(own, kind)
+				break
+			}
+		}
+	}
+	return own
+}
+
 // GetKinds retrieves a list of log types (kinds) from the LogConfigs slice in the AnonymizerConfig struct.
 //
 // Inputs:
@@ -173,3 +392,70 @@ func (cfg *AnonymizerConfig) GetLogConfigByLogType(kind string) (*LogConfig, err
 	}
 	return nil, fmt.Errorf("no config found for file type %s under %s", kind, cfg.AxcVersion)
 }
+
+// GetPathAnonymizer builds the path anonymizer configured for the provided kind.
+//
+// Inputs:
+//   - kind (string): The kind of log for which to build the path anonymizer.
+//   - pseudonymizer (*Pseudonymizer): The source of replacement tokens.
+//
+// Outputs:
+//   - *PathAnonymizer: The path anonymizer, or nil if the kind has no path configuration.
+//   - error: An error indicating if the path configuration is invalid.
+func (cfg *AnonymizerConfig) GetPathAnonymizer(kind string, pseudonymizer *Pseudonymizer) (*PathAnonymizer, error) {
+	logCfg, err := cfg.GetLogConfigByLogType(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if logCfg.Paths == nil {
+		return nil, nil
+	}
+
+	return NewPathAnonymizer(logCfg.Paths, pseudonymizer)
+}
+
+// GetAllowlist builds the allowlist for the provided kind from the global and per-kind allowlists.
+//
+// Inputs:
+//   - kind (string): The kind of log for which to build the allowlist.
+//
+// Outputs:
+//   - *Allowlist: The merged allowlist.
+//   - error: An error indicating if the kind is unknown or a pattern is invalid.
+func (cfg *AnonymizerConfig) GetAllowlist(kind string) (*Allowlist, error) {
+	logCfg, err := cfg.GetLogConfigByLogType(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAllowlist(cfg.Allowlist, logCfg.Allowlist)
+}
+
+// GetDenylists loads the global and per-kind denylists for the provided kind.
+//
+// Inputs:
+//   - kind (string): The kind of log for which to load the denylists.
+//
+// Outputs:
+//   - []*TermMatcher: The compiled denylists, global first.
+//   - error: An error indicating if the kind is unknown or a term list cannot be read.
+func (cfg *AnonymizerConfig) GetDenylists(kind string) ([]*TermMatcher, error) {
+	logCfg, err := cfg.GetLogConfigByLogType(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var denylists []*TermMatcher
+	for _, denyCfg := range []*DenylistConfig{cfg.Denylist, logCfg.Denylist} {
+		matcher, err := LoadDenylist(denyCfg)
+		if err != nil {
+			return nil, err
+		}
+		if matcher != nil {
+			denylists = append(denylists, matcher)
+		}
+	}
+
+	return denylists, nil
+}
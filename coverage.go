@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const DEFAULT_COVERAGE_TOP = 10
+
+// userTokenRegexes find user-like tokens (email addresses, IP addresses,
+// user '...') in lines none of the patterns matched.
+var userTokenRegexes = func() []*regexp.Regexp {
+	var regexes []*regexp.Regexp
+	for _, detector := range builtinDetectors {
+		regexes = append(regexes, regexp.MustCompile(detector))
+	}
+	return regexes
+}()
+
+var digitsRegex = regexp.MustCompile(`\d+`)
+
+// Coverage counts how often every pattern of each kind and axcVersion matches
+// across a corpus of log files.
+type Coverage struct {
+	mu    sync.Mutex
+	kinds map[string]*kindCoverage // axcVersion/kind -> coverage
+}
+
+type kindCoverage struct {
+	version   string
+	kind      string
+	patterns  []Pattern
+	matches   []int
+	files     int
+	lines     int
+	unmatched map[string]int // normalized unmatched line with user-like tokens -> occurrences
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{kinds: map[string]*kindCoverage{}}
+}
+
+// addProfile registers every kind of an axcVersion profile, so kinds without
+// log files are reported as well.
+func (cv *Coverage) addProfile(cfg *AnonymizerConfig) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	for _, kind := range cfg.kindsInOrder() {
+		key := cfg.AxcVersion + "/" + kind
+		if _, ok := cv.kinds[key]; ok {
+			continue
+		}
+		patterns, _ := cfg.GetRegexPatterns(kind)
+		cv.kinds[key] = &kindCoverage{
+			version:   cfg.AxcVersion,
+			kind:      kind,
+			patterns:  patterns,
+			matches:   make([]int, len(patterns)),
+			unmatched: map[string]int{},
+		}
+	}
+}
+
+// coverLogFile matches every regex pattern of the kind of a log file against
+// each of its lines.
+//
+// Parameters:
+//   - ctx: aborts scanning when cancelled
+//   - info: the log file and its kind
+//
+// Returns:
+//   - error: any error encountered while reading the file
+func (s *Scheduler) coverLogFile(ctx context.Context, info logFileInfo) error {
+	s.coverage.addProfile(info.cfg)
+
+	rules, err := s.getRuleSet(info.cfg, info.kind)
+	if err != nil {
+		return err
+	}
+
+	inf, err := os.Open(info.path)
+	if err != nil {
+		return err
+	}
+	defer inf.Close()
+
+	matches := make([]int, len(rules.regexes))
+	unmatched := map[string]int{}
+	lines := 0
+
+	fs := bufio.NewScanner(inf)
+	for fs.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("aborted %s: %w", info.path, err)
+		}
+		line := fs.Text()
+		lines++
+
+		matched := false
+		for i, re := range rules.regexes {
+			if re.Regex.MatchString(line) {
+				matches[i]++
+				matched = true
+			}
+		}
+		if !matched {
+			if normalized, ok := normalizeUserTokens(line); ok {
+				unmatched[normalized]++
+			}
+		}
+	}
+	if err := fs.Err(); err != nil {
+		return err
+	}
+
+	s.coverage.add(info.cfg.AxcVersion, info.kind, rules.regexes, matches, unmatched, lines)
+	return nil
+}
+
+// add merges the counts of a single log file.
+func (cv *Coverage) add(version, kind string, patterns []Pattern, matches []int, unmatched map[string]int, lines int) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	key := version + "/" + kind
+	kc, ok := cv.kinds[key]
+	if !ok {
+		// the generic kind is not part of the profile
+		kc = &kindCoverage{version: version, kind: kind, patterns: patterns, matches: make([]int, len(patterns)), unmatched: map[string]int{}}
+		cv.kinds[key] = kc
+	}
+
+	kc.files++
+	kc.lines += lines
+	for i, n := range matches {
+		kc.matches[i] += n
+	}
+	for line, n := range unmatched {
+		kc.unmatched[line] += n
+	}
+}
+
+// normalizeUserTokens replaces the user-like tokens of line by their category and
+// numbers by #, so lines written by the same log statement are counted together.
+//
+// Returns:
+//   - string: The normalized line.
+//   - bool: true if the line contains a user-like token.
+func normalizeUserTokens(line string) (string, bool) {
+	found := false
+	for _, rex := range userTokenRegexes {
+		category := "<" + strings.ToUpper(rex.SubexpNames()[1]) + ">"
+		line = rex.ReplaceAllStringFunc(line, func(matched string) string {
+			found = true
+			loc := rex.FindStringSubmatchIndex(matched)
+			return matched[:loc[2]] + category + matched[loc[3]:]
+		})
+	}
+	if !found {
+		return "", false
+	}
+
+	return digitsRegex.ReplaceAllString(line, "#"), true
+}
+
+// Print writes the coverage report: per axcVersion and kind the number of
+// matches of each pattern, the patterns that never matched and the top most
+// frequent unmatched lines with user-like tokens.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - top (int): The number of unmatched lines to show per kind.
+func (cv *Coverage) Print(w io.Writer, top int) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	for _, key := range sortedKeys(cv.kinds) {
+		kc := cv.kinds[key]
+		fmt.Fprintf(w, "%s %s: %d files, %d lines\n", kc.version, kc.kind, kc.files, kc.lines)
+
+		var dead []string
+		for i, pattern := range kc.patterns {
+			name := pattern.Name
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(w, "    %-4d%-10d%-20s%s\n", i+1, kc.matches[i], name, pattern.Pattern)
+			if kc.matches[i] == 0 {
+				dead = append(dead, fmt.Sprintf("%d", i+1))
+			}
+		}
+		if len(dead) > 0 && kc.files > 0 {
+			fmt.Fprintf(w, "    never matched: %s\n", strings.Join(dead, ", "))
+		}
+
+		if len(kc.unmatched) == 0 {
+			continue
+		}
+		lines := sortedKeys(kc.unmatched)
+		sort.SliceStable(lines, func(i, j int) bool { return kc.unmatched[lines[i]] > kc.unmatched[lines[j]] })
+		if len(lines) > top {
+			lines = lines[:top]
+		}
+		fmt.Fprintln(w, "    unmatched lines with user-like tokens:")
+		for _, line := range lines {
+			fmt.Fprintf(w, "        %-8d%s\n", kc.unmatched[line], line)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EMBEDDED_CONFIG is the name the embedded configuration is reported under.
+const EMBEDDED_CONFIG = "embedded:" + DEFAULT_CONFIG
+
+// defaultConfig is the shipped config.yaml, used when no configuration is found.
+//
+//go:embed config.yaml
+var defaultConfig []byte
+
+// LoadEmbeddedConfig loads the configuration built into the binary.
+// Relative includes are resolved against the working directory.
+//
+// Outputs:
+//   - *AnonymizerConfiguration: The embedded configuration.
+//   - error: An error if the embedded configuration cannot be parsed.
+func LoadEmbeddedConfig() (*AnonymizerConfiguration, error) {
+	absPath, err := filepath.Abs(DEFAULT_CONFIG)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfigData(EMBEDDED_CONFIG, absPath, defaultConfig, nil)
+}
+
+// WriteEmbeddedConfig writes the embedded configuration to path.
+//
+// Inputs:
+//   - path (string): The file to write.
+//   - force (bool): Overwrite an existing file.
+//
+// Outputs:
+//   - error: An error if the file exists and force is not set, or cannot be written.
+func WriteEmbeddedConfig(path string, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(defaultConfig); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
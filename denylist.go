@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadDenylist reads the terms of a denylist configuration and compiles them into a TermMatcher.
+// Files ending in .csv contribute every non-empty field, any other file one term per line.
+// Lines starting with # are comments.
+//
+// Parameters:
+//   - cfg (*DenylistConfig): The denylist configuration.
+//
+// Returns:
+//   - *TermMatcher: The compiled matcher, or nil if cfg is nil.
+//   - error: An error if one of the term files cannot be read.
+func LoadDenylist(cfg *DenylistConfig) (*TermMatcher, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	terms := append([]string{}, cfg.Terms...)
+
+	for _, file := range cfg.Files {
+		fileTerms, err := readTermFile(file, cfg.CSVHeader)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, fileTerms...)
+	}
+
+	return NewTermMatcher(terms, cfg.WholeWord), nil
+}
+
+// resolveDenylistFiles makes the relative term files of every denylist absolute,
+// resolved against dir, the directory of the configuration file, as includes are.
+func (cfg *AnonymizerConfiguration) resolveDenylistFiles(dir string) {
+	resolve := func(denyCfg *DenylistConfig) {
+		if denyCfg == nil {
+			return
+		}
+		for i, file := range denyCfg.Files {
+			if !filepath.IsAbs(file) {
+				denyCfg.Files[i] = filepath.Join(dir, file)
+			}
+		}
+	}
+
+	for i := range cfg.AnonymizerConfigs {
+		resolve(cfg.AnonymizerConfigs[i].Denylist)
+		for j := range cfg.AnonymizerConfigs[i].LogConfigs {
+			resolve(cfg.AnonymizerConfigs[i].LogConfigs[j].Denylist)
+		}
+	}
+}
+
+// readTermFile reads the terms of a plain text or CSV term list.
+func readTermFile(path string, csvHeader bool) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading denylist %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var terms []string
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		r.Comment = '#'
+		for header := csvHeader; ; header = false {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading denylist %s: %w", path, err)
+			}
+			if header {
+				continue
+			}
+			for _, field := range record {
+				if field = strings.TrimSpace(field); field != "" {
+					terms = append(terms, field)
+				}
+			}
+		}
+		return terms, nil
+	}
+
+	fs := bufio.NewScanner(f)
+	for fs.Scan() {
+		term := strings.TrimSpace(fs.Text())
+		if term == "" || strings.HasPrefix(term, "#") {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	if err := fs.Err(); err != nil {
+		return nil, fmt.Errorf("reading denylist %s: %w", path, err)
+	}
+
+	return terms, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDenylistFilesRelativeToConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lists"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lists", "custodians.txt"), []byte("# custodians\nJane Doe\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+anonymizer:
+  - axcVersion: default
+    denylist:
+      files: [lists/custodians.txt]
+    logs:
+      - kind: engine
+        namingPatterns: ["MindServer.*[.]log"]
+        regexPatterns: [".*User : (.*?) .*"]
+`
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the working directory is not the directory of the configuration
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := cfg.GetAnonymizerConfigByAxcVersion(DEFAULT_AXC_VERSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denylists, err := profile.GetDenylists("engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denylists) != 1 || denylists[0].Len() != 1 {
+		t.Fatalf("expected one denylist with one term, got %d", len(denylists))
+	}
+	if got, _ := denylists[0].Replace("mail from jane doe", func(string) string { return "[X]" }); got != "mail from [X]" {
+		t.Errorf("denylist term not redacted: %q", got)
+	}
+}
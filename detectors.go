@@ -0,0 +1,41 @@
+package main
+
+// GENERIC_KIND is the kind applied to unrecognised log files with --unknownFiles generic.
+// Unless the configuration defines a kind of that name it only uses built-in detectors.
+const GENERIC_KIND = "generic"
+
+// builtinDetectors are regex patterns for values that are sensitive in any log.
+// Their named groups make them replace values with category tokens like <EMAIL_1>.
+var builtinDetectors = []string{
+	`(?P<email>[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})`,                     // email address
+	`\b(?P<ip>(?:25[0-5]|2[0-4]\d|1?\d?\d)(?:\.(?:25[0-5]|2[0-4]\d|1?\d?\d)){3})\b`, // IPv4 address
+	`(?i)\buser(?:name)?\s*[:=]?\s*'(?P<user>[^']+)'`,                               // user 'jdoe'
+}
+
+// genericLogConfig returns the built-in configuration of GENERIC_KIND.
+func genericLogConfig() LogConfig {
+	return LogConfig{
+		Kind:          GENERIC_KIND,
+		RegexPatterns: builtinDetectors,
+		Paths: &PathConfig{
+			Segments: []string{PATH_SEGMENT_USER, PATH_SEGMENT_SERVER, PATH_SEGMENT_SHARE},
+		},
+	}
+}
+
+// withGenericKind returns a copy of the AnonymizerConfig that has a GENERIC_KIND
+// log config, adding the built-in one if the configuration does not define it.
+//
+// Outputs:
+//   - *AnonymizerConfig: The configuration including GENERIC_KIND.
+func (cfg *AnonymizerConfig) withGenericKind() *AnonymizerConfig {
+	for _, logCfg := range cfg.LogConfigs {
+		if logCfg.Kind == GENERIC_KIND {
+			return cfg
+		}
+	}
+
+	generic := *cfg
+	generic.LogConfigs = append(append([]LogConfig{}, cfg.LogConfigs...), genericLogConfig())
+	return &generic
+}
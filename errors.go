@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of log-anonymizer.
+const (
+	EXIT_OK              = 0
+	EXIT_ERROR           = 1 // usage or unexpected errors
+	EXIT_CONFIG_ERROR    = 2 // configuration could not be loaded
+	EXIT_PARTIAL_FAILURE = 3 // some files failed, were aborted or never started
+	EXIT_TOTAL_FAILURE   = 4 // no file was anonymized
+	EXIT_RESIDUAL_PII    = 5 // verification found sensitive values in the output
+
+	EXIT_UNRECOGNISED_FILES = 6 // log files of unknown kind with --unknownFiles fail or quarantine
+	EXIT_SELFTEST_FAILURE   = 7 // golden cases of selftest differ from their expected output
+)
+
+// ExitError carries the exit code the process should terminate with.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// withExitCode wraps err so that main exits with code.
+//
+// Parameters:
+//   - code (int): The exit code.
+//   - err (error): The error to wrap, nil stays nil.
+//
+// Returns:
+//   - error: The wrapped error.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// exitCode returns the exit code for an error returned by the app.
+func exitCode(err error) int {
+	if err == nil {
+		return EXIT_OK
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return EXIT_ERROR
+}
+
+// aggregateResults turns the outcome of a run into a single error. Quarantined
+// files are not counted as anonymized: they are reported on their own once
+// every other file completed.
+//
+// Parameters:
+//   - results ([]FileResult): The outcome of each log file.
+//   - residualFiles (int): The number of output files verification found sensitive values in.
+//
+// Returns:
+//   - error: nil if every file was anonymized cleanly, an *ExitError otherwise.
+func aggregateResults(results []FileResult, residualFiles int) error {
+	incomplete, quarantined := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case FILE_COMPLETED:
+		case FILE_QUARANTINED:
+			quarantined++
+		default:
+			incomplete++
+		}
+	}
+	total := len(results) - quarantined
+
+	switch {
+	case residualFiles > 0:
+		return withExitCode(EXIT_RESIDUAL_PII, fmt.Errorf("residual sensitive values found in %d of %d files", residualFiles, total))
+	case incomplete > 0 && incomplete == total:
+		return withExitCode(EXIT_TOTAL_FAILURE, fmt.Errorf("none of %d files were anonymized", total))
+	case incomplete > 0:
+		return withExitCode(EXIT_PARTIAL_FAILURE, fmt.Errorf("%d of %d files were not anonymized", incomplete, total))
+	case quarantined > 0:
+		return withExitCode(EXIT_UNRECOGNISED_FILES, fmt.Errorf("%d unrecognised log files were quarantined, see %s", quarantined, QUARANTINE_MANIFEST))
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestAggregateResults(t *testing.T) {
+	results := func(statuses ...string) []FileResult {
+		var results []FileResult
+		for _, status := range statuses {
+			results = append(results, FileResult{Status: status})
+		}
+		return results
+	}
+
+	tests := []struct {
+		name     string
+		results  []FileResult
+		residual int
+		code     int
+		message  string
+	}{
+		{"all completed", results(FILE_COMPLETED, FILE_COMPLETED), 0, EXIT_OK, ""},
+		{"no files", nil, 0, EXIT_OK, ""},
+		{"residual before total", results(FILE_FAILED, FILE_FAILED), 1, EXIT_RESIDUAL_PII, "residual sensitive values found in 1 of 2 files"},
+		{"residual before partial", results(FILE_COMPLETED, FILE_FAILED, FILE_QUARANTINED), 1, EXIT_RESIDUAL_PII, "residual sensitive values found in 1 of 2 files"},
+		{"total before quarantined", results(FILE_FAILED, FILE_ABORTED, FILE_NOT_STARTED, FILE_QUARANTINED), 0, EXIT_TOTAL_FAILURE, "none of 3 files were anonymized"},
+		{"partial before quarantined", results(FILE_COMPLETED, FILE_FAILED, FILE_QUARANTINED, FILE_QUARANTINED), 0, EXIT_PARTIAL_FAILURE, "1 of 2 files were not anonymized"},
+		{"quarantined", results(FILE_COMPLETED, FILE_QUARANTINED), 0, EXIT_UNRECOGNISED_FILES, "1 unrecognised log files were quarantined, see " + QUARANTINE_MANIFEST},
+		{"only quarantined", results(FILE_QUARANTINED, FILE_QUARANTINED), 0, EXIT_UNRECOGNISED_FILES, "2 unrecognised log files were quarantined, see " + QUARANTINE_MANIFEST},
+	}
+
+	for _, tt := range tests {
+		err := aggregateResults(tt.results, tt.residual)
+		if code := exitCode(err); code != tt.code {
+			t.Errorf("%s: exit code = %d, want %d (%v)", tt.name, code, tt.code, err)
+		}
+		if tt.message == "" {
+			if err != nil {
+				t.Errorf("%s: error = %v, want nil", tt.name, err)
+			}
+		} else if err == nil || err.Error() != tt.message {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.message)
+		}
+	}
+}
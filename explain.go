@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// classifyLogFile determines the axcVersion profile and kind of a single log
+// file the same way getLogs does.
+//
+// Parameters:
+//   - path (string): The log file.
+//
+// Returns:
+//   - *AnonymizerConfig: The axcVersion profile of the log file.
+//   - string: The kind of the log file.
+//   - error: An error if the file cannot be read or its kind is unknown.
+func (s *Scheduler) classifyLogFile(path string) (*AnonymizerConfig, string, error) {
+	cfg := GlobalConfig
+	if s.autoVersion {
+		var err error
+		if cfg, err = s.detectVersion(path); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if s.kind != DEFAULT_KIND {
+		return cfg, s.kind, nil
+	}
+
+	kind, err := s.detectKind(cfg, path)
+	return cfg, kind, err
+}
+
+// readLine returns line n (1-based) of a file.
+func readLine(path string, n int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fs := bufio.NewScanner(f)
+	fs.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for i := 1; fs.Scan(); i++ {
+		if i == n {
+			return fs.Text(), nil
+		}
+	}
+	if err := fs.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%s has no line %d", path, n)
+}
+
+// Explain prints how a line is anonymized: every regex pattern of the kind in
+// order, whether it matched, the decision for each captured value and the line
+// after each rule, followed by the path anonymizer and the denylists. Learned
+// terms are not applied as they depend on the other lines of a run.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - cfg (*AnonymizerConfig): The axcVersion profile of the line.
+//   - kind (string): The log kind of the line.
+//   - line (string): The line to explain.
+//
+// Returns:
+//   - string: The anonymized line.
+//   - error: An error if the rules of the kind cannot be built.
+func (s *Scheduler) Explain(w io.Writer, cfg *AnonymizerConfig, kind, line string) (string, error) {
+	rules, err := s.getRuleSet(cfg, kind)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(w, "%-12s%s\n", "kind", kind)
+	fmt.Fprintf(w, "%-12s%s\n", "axcVersion", cfg.AxcVersion)
+	fmt.Fprintf(w, "%-12s%s\n", "input", line)
+
+	for i, re := range rules.regexes {
+		name := re.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "regex %-4d%-20s%s\n", i+1, name, re.Pattern)
+
+		if !writeGroups(w, re, rules.allowlist, line) {
+			fmt.Fprintln(w, "    no match")
+			continue
+		}
+		line = s.applyPattern(line, re, rules, nil)
+		fmt.Fprintf(w, "    => %s\n", line)
+	}
+
+	if rules.paths == nil {
+		fmt.Fprintln(w, "paths       disabled")
+	} else if anonymized := rules.paths.Anonymize(line); anonymized != line {
+		line = anonymized
+		fmt.Fprintf(w, "paths       => %s\n", line)
+	} else {
+		fmt.Fprintln(w, "paths       no change")
+	}
+
+	for i, denylist := range rules.denylists {
+		var count int
+		line, count = s.applyTerms(line, denylist, CATEGORY_DENYLIST)
+		if count == 0 {
+			fmt.Fprintf(w, "denylist %-3dno match (%d terms)\n", i+1, denylist.Len())
+			continue
+		}
+		fmt.Fprintf(w, "denylist %-3d%d redacted => %s\n", i+1, count, line)
+	}
+
+	fmt.Fprintf(w, "%-12snot applied, they depend on the other lines of a run\n", "learned")
+	fmt.Fprintf(w, "%-12s%s\n", "output", line)
+
+	return line, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplainReplacesCapturedSpan(t *testing.T) {
+	cfg := &AnonymizerConfig{
+		AxcVersion: "test",
+		LogConfigs: []LogConfig{{Kind: "service", RegexPatterns: []string{`user '(.*?)'`}}},
+	}
+
+	// the captured value also appears earlier in the line
+	line := "jdoe: login of user 'jdoe'"
+	want := "jdoe: login of user '[X]'"
+
+	var w bytes.Buffer
+	got, err := NewScheduler().WithObfuscation("[X]").Explain(&w, cfg, "service", line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Explain(%q) = %q, want %q", line, got, want)
+	}
+
+	for _, report := range []string{`[21:25] "jdoe" replaced`, "    => " + want, "output      " + want} {
+		if !strings.Contains(w.String(), report) {
+			t.Errorf("Explain report lacks %q:\n%s", report, w.String())
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Golden files
+//
+// A golden directory holds regression cases laid out as
+//
+//	<dir>/<axcVersion>/<kind>/input.log
+//	<dir>/<axcVersion>/<kind>/expected.log
+//
+// or one level deeper, <dir>/<axcVersion>/<kind>/<case>/, to keep several cases
+// per kind. Each case is anonymized on its own by a fresh scheduler, so pseudonym
+// tokens start at 1 in every case, and the output is compared with expected.log.
+// Cases go through Process like run does, so propagation, two-pass mode and
+// verification are exercised as configured; the axcVersion is taken from the
+// directory. So is the kind, except below <axcVersion>/detect/<log file name>/,
+// where input.log is detected under the name of its case directory like run
+// detects the kind of a log file, and an ambiguous kind fails the case.
+
+const (
+	GOLDEN_INPUT     = "input.log"
+	GOLDEN_EXPECTED  = "expected.log"
+	GOLDEN_MAX_DIFFS = 10 // differing lines reported per case
+
+	GOLDEN_DETECT_KIND = "detect" // kind directory of cases whose kind is detected
+)
+
+// GoldenCase is an input.log/expected.log pair of a kind and axcVersion.
+type GoldenCase struct {
+	Name    string // directory of the case relative to the golden directory
+	Dir     string
+	Version string
+	Kind    string
+}
+
+// FindGoldenCases collects the golden cases below dir.
+//
+// Parameters:
+//   - dir (string): The golden directory.
+//
+// Returns:
+//   - []GoldenCase: The cases in lexical order.
+//   - error: An error if dir cannot be walked or a case is not below <axcVersion>/<kind>.
+func FindGoldenCases(dir string) ([]GoldenCase, error) {
+	var cases []GoldenCase
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != GOLDEN_INPUT {
+			return nil
+		}
+
+		caseDir := filepath.Dir(path)
+		name, err := filepath.Rel(dir, caseDir)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(filepath.ToSlash(name), "/")
+		if len(parts) < 2 {
+			return fmt.Errorf("golden case %s is not below <axcVersion>/<kind>", path)
+		}
+		if parts[1] == GOLDEN_DETECT_KIND && len(parts) < 3 {
+			return fmt.Errorf("golden case %s is not below <axcVersion>/%s/<log file name>", path, GOLDEN_DETECT_KIND)
+		}
+		cases = append(cases, GoldenCase{Name: filepath.ToSlash(name), Dir: caseDir, Version: parts[0], Kind: parts[1]})
+		return nil
+	})
+
+	return cases, err
+}
+
+// RunGoldenCase anonymizes the input of a golden case with a fresh scheduler and
+// compares the result with its expected output.
+//
+// Parameters:
+//   - ctx (context.Context): Aborts the case when cancelled.
+//   - cfg (*AnonymizerConfiguration): The configuration under test.
+//   - gc (GoldenCase): The case.
+//   - newScheduler (func() *Scheduler): Returns a scheduler configured like the run under test.
+//   - update (bool): Write the actual output to expected.log instead of comparing.
+//
+// Returns:
+//   - []string: The differences, empty if the output is as expected.
+//   - error: An error if the case cannot be run.
+func RunGoldenCase(ctx context.Context, cfg *AnonymizerConfiguration, gc GoldenCase, newScheduler func() *Scheduler, update bool) ([]string, error) {
+	profile, err := cfg.GetAnonymizerConfigByAxcVersion(gc.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := os.MkdirTemp("", "log-anonymizer-selftest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+	outputDir := filepath.Join(tempDir, "output")
+
+	s := newScheduler().
+		WithKind(gc.Kind).
+		WithWorkerCount(1).
+		WithOutputDir(outputDir).
+		WithKeepNames(true)
+
+	info := logFileInfo{kind: gc.Kind, path: filepath.Join(gc.Dir, GOLDEN_INPUT), relPath: GOLDEN_INPUT, cfg: profile}
+
+	var diffs []string
+	if gc.Kind == GOLDEN_DETECT_KIND {
+		if info, err = detectGoldenKind(s, info, filepath.Join(tempDir, "input")); err != nil {
+			return nil, err
+		}
+		if kinds := s.stats.Ambiguous(info.path); len(kinds) > 0 {
+			diffs = append(diffs, fmt.Sprintf("ambiguous kind: %s", strings.Join(kinds, ", ")))
+		}
+	}
+
+	result := s.Process(ctx, []logFileInfo{info})[0]
+	if result.Status != FILE_COMPLETED {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return nil, fmt.Errorf("%s", result.Status)
+	}
+
+	actual, err := os.ReadFile(info.getOutputFileName(outputDir, true))
+	if err != nil {
+		return nil, err
+	}
+
+	expectedPath := filepath.Join(gc.Dir, GOLDEN_EXPECTED)
+	if update {
+		return nil, os.WriteFile(expectedPath, actual, 0644)
+	}
+
+	expected, err := os.ReadFile(expectedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs = append(diffs, diffLines(splitLines(string(expected)), splitLines(string(actual)))...)
+	if s.stats.ResidualFiles() > 0 {
+		diffs = append(diffs, "verification found residual sensitive values")
+	}
+	return diffs, nil
+}
+
+// detectGoldenKind copies the input of a detect case to inputDir under the name of
+// its case directory and detects its kind like run does.
+//
+// Parameters:
+//   - s (*Scheduler): The scheduler of the case.
+//   - info (logFileInfo): The input of the case.
+//   - inputDir (string): The directory the input is copied to.
+//
+// Returns:
+//   - logFileInfo: The copied input with its detected kind.
+//   - error: An error if the input cannot be copied or its kind cannot be detected.
+func detectGoldenKind(s *Scheduler, info logFileInfo, inputDir string) (logFileInfo, error) {
+	content, err := os.ReadFile(info.path)
+	if err != nil {
+		return info, err
+	}
+
+	name := filepath.Base(filepath.Dir(info.path))
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		return info, err
+	}
+	info.path, info.relPath = filepath.Join(inputDir, name), name
+	if err := os.WriteFile(info.path, content, 0644); err != nil {
+		return info, err
+	}
+
+	s.WithKind("*").WithSniffLines(DEFAULT_SNIFF_LINES)
+	info.kind, err = s.detectKind(info.cfg, info.path)
+	return info, err
+}
+
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines compares expected and actual output line by line and describes up
+// to GOLDEN_MAX_DIFFS differing lines.
+func diffLines(expected, actual []string) []string {
+	var diffs []string
+
+	n := len(expected)
+	if len(actual) > n {
+		n = len(actual)
+	}
+	for i := 0; i < n; i++ {
+		var want, got string
+		if i < len(expected) {
+			want = expected[i]
+		}
+		if i < len(actual) {
+			got = actual[i]
+		}
+		if i < len(expected) && i < len(actual) && want == got {
+			continue
+		}
+
+		if len(diffs) == GOLDEN_MAX_DIFFS {
+			diffs = append(diffs, "...")
+			break
+		}
+		switch {
+		case i >= len(actual):
+			diffs = append(diffs, fmt.Sprintf("line %d missing\n    - %s", i+1, want))
+		case i >= len(expected):
+			diffs = append(diffs, fmt.Sprintf("line %d unexpected\n    + %s", i+1, got))
+		default:
+			diffs = append(diffs, fmt.Sprintf("line %d\n    - %s\n    + %s", i+1, want, got))
+		}
+	}
+
+	return diffs
+}
+
+// RunGoldenCases runs every golden case below dir and reports the result of each.
+//
+// Parameters:
+//   - ctx (context.Context): Aborts the run when cancelled.
+//   - w (io.Writer): The destination of the report.
+//   - cfg (*AnonymizerConfiguration): The configuration under test.
+//   - dir (string): The golden directory.
+//   - newScheduler (func() *Scheduler): Returns a scheduler configured like the run under test.
+//   - update (bool): Write the actual output to expected.log instead of comparing.
+//
+// Returns:
+//   - int: The number of failed cases.
+//   - int: The number of cases.
+//   - error: An error if the golden directory cannot be read.
+func RunGoldenCases(ctx context.Context, w io.Writer, cfg *AnonymizerConfiguration, dir string, newScheduler func() *Scheduler, update bool) (int, int, error) {
+	cases, err := FindGoldenCases(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(cases) == 0 {
+		return 0, 0, fmt.Errorf("no %s found below %s", GOLDEN_INPUT, dir)
+	}
+
+	failed := 0
+	for _, gc := range cases {
+		diffs, err := RunGoldenCase(ctx, cfg, gc, newScheduler, update)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(w, "%-8s%s: %s\n", "ERROR", gc.Name, err)
+		case update:
+			fmt.Fprintf(w, "%-8s%s\n", "UPDATED", gc.Name)
+		case len(diffs) > 0:
+			failed++
+			fmt.Fprintf(w, "%-8s%s\n", "FAIL", gc.Name)
+			for _, diff := range diffs {
+				fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(diff, "\n", "\n    "))
+			}
+		default:
+			fmt.Fprintf(w, "%-8s%s\n", "PASS", gc.Name)
+		}
+	}
+
+	return failed, len(cases), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// The golden tests run the shipped config.yaml against testdata/golden. Customised
+// configurations are regression-tested by pointing the environment at them:
+//
+//	LOG_ANONYMIZER_CONFIG=base.yaml:custom.yaml LOG_ANONYMIZER_GOLDEN=./golden go test -run TestGolden
+//
+// go test -run TestGolden -update rewrites the expected.log files.
+
+var update = flag.Bool("update", false, "write the actual output to expected.log instead of comparing")
+
+func TestGolden(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	configs := []string{DEFAULT_CONFIG}
+	if env := os.Getenv("LOG_ANONYMIZER_CONFIG"); env != "" {
+		configs = filepath.SplitList(env)
+	}
+	dir := DEFAULT_GOLDEN_DIR
+	if env := os.Getenv("LOG_ANONYMIZER_GOLDEN"); env != "" {
+		dir = env
+	}
+
+	cfg, err := LoadConfig(configs...)
+	if err != nil {
+		t.Fatalf("loading %s: %s", strings.Join(configs, ", "), err)
+	}
+
+	cases, err := FindGoldenCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("no %s found below %s", GOLDEN_INPUT, dir)
+	}
+
+	newScheduler := func() *Scheduler {
+		return NewScheduler().
+			WithObfuscation(DEFAULT_OBFUSCATION).
+			WithVerify(!*update)
+	}
+
+	for _, gc := range cases {
+		gc := gc
+		t.Run(gc.Name, func(t *testing.T) {
+			diffs, err := RunGoldenCase(context.Background(), cfg, gc, newScheduler, *update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, diff := range diffs {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGoldenDetectsDifferences(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	cfg, err := LoadConfig(DEFAULT_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "default", "service")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	input := "Start login for user 'jdoe'\nHeartbeat ok\n"
+	if err := os.WriteFile(filepath.Join(dir, GOLDEN_INPUT), []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// an expected output that leaks the user name
+	if err := os.WriteFile(filepath.Join(dir, GOLDEN_EXPECTED), []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := GoldenCase{Name: "default/service", Dir: dir, Version: "default", Kind: "service"}
+	newScheduler := func() *Scheduler { return NewScheduler().WithObfuscation(DEFAULT_OBFUSCATION) }
+	diffs, err := RunGoldenCase(context.Background(), cfg, gc, newScheduler, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "line 1") {
+		t.Errorf("expected a difference in line 1, got %q", diffs)
+	}
+}
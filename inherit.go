@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveAnonymizerConfig returns the merged view of the profile for version,
+// following its extends chain.
+//
+// Inputs:
+//   - version (string): The axcVersion of the profile.
+//   - visiting ([]string): The profiles of the extends chain so far, used to detect cycles.
+//
+// Outputs:
+//   - *AnonymizerConfig: The merged profile.
+//   - error: An error if a profile is missing or the extends chain has a cycle.
+func (cfg *AnonymizerConfiguration) resolveAnonymizerConfig(version string, visiting []string) (*AnonymizerConfig, error) {
+	for _, v := range visiting {
+		if v == version {
+			return nil, fmt.Errorf("cyclic extends: %s -> %s", strings.Join(visiting, " -> "), version)
+		}
+	}
+
+	var profile *AnonymizerConfig
+	for i := range cfg.AnonymizerConfigs {
+		if cfg.AnonymizerConfigs[i].AxcVersion == version {
+			profile = &cfg.AnonymizerConfigs[i]
+			break
+		}
+	}
+	if profile == nil {
+		return nil, fmt.Errorf("no config found for version %s", version)
+	}
+
+	if profile.Extends == "" {
+		resolved := mergeAnonymizerConfig(AnonymizerConfig{}, *profile)
+		resolved.library = cfg.Patterns
+		resolved.categories = cfg.Categories
+		return &resolved, nil
+	}
+
+	base, err := cfg.resolveAnonymizerConfig(profile.Extends, append(visiting, version))
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := mergeAnonymizerConfig(*base, *profile)
+	resolved.library = cfg.Patterns
+	resolved.categories = cfg.Categories
+	return &resolved, nil
+}
+
+// mergeAnonymizerConfig overlays a profile on a base profile. Kinds of the overlay
+// are merged into the base kind of the same name unless they set override or
+// remove; kinds only in the overlay are added. Base kinds the overlay does not
+// mention are marked inherited, so detectKind prefers the overlay's own kinds.
+// Allowlist and denylist of the overlay replace those of the base. Version
+// detection is never inherited.
+//
+// Inputs:
+//   - base (AnonymizerConfig): The inherited profile.
+//   - overlay (AnonymizerConfig): The profile overriding it.
+//
+// Outputs:
+//   - AnonymizerConfig: The merged profile, sharing no slices with its inputs.
+func mergeAnonymizerConfig(base, overlay AnonymizerConfig) AnonymizerConfig {
+	merged := AnonymizerConfig{
+		AxcVersion:       overlay.AxcVersion,
+		VersionDetection: append([]string{}, overlay.VersionDetection...),
+		Allowlist:        base.Allowlist,
+		Denylist:         base.Denylist,
+	}
+	if overlay.Allowlist != nil {
+		merged.Allowlist = overlay.Allowlist
+	}
+	if overlay.Denylist != nil {
+		merged.Denylist = overlay.Denylist
+	}
+
+	for _, logCfg := range base.LogConfigs {
+		inherited := mergeLogConfig(LogConfig{}, logCfg)
+		inherited.inherited = true
+		merged.LogConfigs = append(merged.LogConfigs, inherited)
+	}
+
+	for _, logCfg := range overlay.LogConfigs {
+		index := -1
+		for i := range merged.LogConfigs {
+			if merged.LogConfigs[i].Kind == logCfg.Kind {
+				index = i
+				break
+			}
+		}
+
+		switch {
+		case index < 0 && logCfg.Remove:
+		case index < 0:
+			merged.LogConfigs = append(merged.LogConfigs, mergeLogConfig(LogConfig{}, logCfg))
+		case logCfg.Remove:
+			merged.LogConfigs = append(merged.LogConfigs[:index], merged.LogConfigs[index+1:]...)
+		case logCfg.Override:
+			merged.LogConfigs[index] = mergeLogConfig(LogConfig{}, logCfg)
+		default:
+			merged.LogConfigs[index] = mergeLogConfig(merged.LogConfigs[index], logCfg)
+		}
+	}
+
+	return merged
+}
+
+// mergeLogConfig overlays a kind on a base kind of the same name. Patterns are
+// appended unless already present, patterns listed for removal are dropped and
+// path, allowlist and denylist settings of the overlay replace those of the base.
+//
+// Inputs:
+//   - base (LogConfig): The inherited kind.
+//   - overlay (LogConfig): The kind overriding it.
+//
+// Outputs:
+//   - LogConfig: The merged kind, sharing no slices with its inputs.
+func mergeLogConfig(base, overlay LogConfig) LogConfig {
+	merged := LogConfig{
+		Kind:              overlay.Kind,
+		NamingPatterns:    mergePatterns(base.NamingPatterns, overlay.NamingPatterns, overlay.RemoveNamingPatterns),
+		RegexPatterns:     mergePatterns(base.RegexPatterns, overlay.RegexPatterns, overlay.RemoveRegexPatterns),
+		Patterns:          mergePatterns(base.Patterns, overlay.Patterns, overlay.RemoveRegexPatterns),
+		ContentSignatures: mergePatterns(base.ContentSignatures, overlay.ContentSignatures, nil),
+		Paths:             base.Paths,
+		Allowlist:         base.Allowlist,
+		Denylist:          base.Denylist,
+	}
+	if overlay.Paths != nil {
+		merged.Paths = overlay.Paths
+	}
+	if overlay.Allowlist != nil {
+		merged.Allowlist = overlay.Allowlist
+	}
+	if overlay.Denylist != nil {
+		merged.Denylist = overlay.Denylist
+	}
+
+	return merged
+}
+
+// mergePatterns appends the added patterns missing from base and drops the removed ones.
+func mergePatterns(base, added, removed []string) []string {
+	drop := map[string]bool{}
+	for _, pattern := range removed {
+		drop[pattern] = true
+	}
+
+	seen := map[string]bool{}
+	var merged []string
+	for _, pattern := range append(append([]string{}, base...), added...) {
+		if drop[pattern] || seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		merged = append(merged, pattern)
+	}
+
+	return merged
+}
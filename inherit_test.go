@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeAnonymizerConfig(t *testing.T) {
+	base := AnonymizerConfig{
+		AxcVersion:       "default",
+		VersionDetection: []string{`v(\d+)`},
+		Allowlist:        &AllowlistConfig{Values: []string{"base"}},
+		LogConfigs: []LogConfig{
+			{Kind: "engine", NamingPatterns: []string{"Engine.*", "Mind.*"}, RegexPatterns: []string{"A", "B"}, Patterns: []string{"email"}},
+			{Kind: "service", RegexPatterns: []string{"S"}},
+			{Kind: "gateway", RegexPatterns: []string{"G"}},
+		},
+	}
+
+	overlay := AnonymizerConfig{
+		AxcVersion: "v22.0",
+		LogConfigs: []LogConfig{
+			{Kind: "engine", NamingPatterns: []string{"Search.*"}, RegexPatterns: []string{"C"},
+				RemoveNamingPatterns: []string{"Mind.*"}, RemoveRegexPatterns: []string{"A", "email"}},
+			{Kind: "service", RegexPatterns: []string{"T"}, Override: true},
+			{Kind: "gateway", Remove: true},
+			{Kind: "missing", Remove: true},
+			{Kind: "launcher", RegexPatterns: []string{"L"}},
+		},
+	}
+
+	merged := mergeAnonymizerConfig(base, overlay)
+
+	if merged.AxcVersion != "v22.0" {
+		t.Errorf("axcVersion = %q, want v22.0", merged.AxcVersion)
+	}
+	if len(merged.VersionDetection) != 0 {
+		t.Errorf("versionDetection = %q, want it not inherited", merged.VersionDetection)
+	}
+	if merged.Allowlist != base.Allowlist {
+		t.Errorf("allowlist = %+v, want the inherited one", merged.Allowlist)
+	}
+
+	var kinds []string
+	for _, logCfg := range merged.LogConfigs {
+		kinds = append(kinds, logCfg.Kind)
+	}
+	if want := []string{"engine", "service", "launcher"}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %q, want %q", kinds, want)
+	}
+
+	engine, service, launcher := merged.LogConfigs[0], merged.LogConfigs[1], merged.LogConfigs[2]
+	if want := []string{"Engine.*", "Search.*"}; !reflect.DeepEqual(engine.NamingPatterns, want) {
+		t.Errorf("engine namingPatterns = %q, want %q", engine.NamingPatterns, want)
+	}
+	if want := []string{"B", "C"}; !reflect.DeepEqual(engine.RegexPatterns, want) {
+		t.Errorf("engine regexPatterns = %q, want %q", engine.RegexPatterns, want)
+	}
+	if len(engine.Patterns) != 0 {
+		t.Errorf("engine patterns = %q, want email removed", engine.Patterns)
+	}
+	if want := []string{"T"}; !reflect.DeepEqual(service.RegexPatterns, want) {
+		t.Errorf("service regexPatterns = %q, want %q", service.RegexPatterns, want)
+	}
+	if engine.inherited || service.inherited || launcher.inherited {
+		t.Errorf("kinds of the overlay marked inherited: %+v", merged.LogConfigs)
+	}
+
+	engine.RegexPatterns[0] = "changed"
+	if base.LogConfigs[0].RegexPatterns[1] != "B" {
+		t.Error("merged profile shares slices with base")
+	}
+}
+
+func TestMergeAnonymizerConfigMarksInheritedKinds(t *testing.T) {
+	base := AnonymizerConfig{LogConfigs: []LogConfig{{Kind: "engine"}, {Kind: "service"}}}
+	overlay := AnonymizerConfig{AxcVersion: "v22.0", Allowlist: &AllowlistConfig{}, LogConfigs: []LogConfig{{Kind: "engine", RegexPatterns: []string{"A"}}}}
+
+	merged := mergeAnonymizerConfig(base, overlay)
+	if merged.LogConfigs[0].inherited || !merged.LogConfigs[1].inherited {
+		t.Errorf("inherited = %v, %v, want only service inherited", merged.LogConfigs[0].inherited, merged.LogConfigs[1].inherited)
+	}
+	if merged.Allowlist != overlay.Allowlist {
+		t.Error("allowlist of the overlay does not replace the inherited one")
+	}
+}
+
+func TestResolveAnonymizerConfigExtends(t *testing.T) {
+	profiles := func(extends map[string]string) *AnonymizerConfiguration {
+		cfg := &AnonymizerConfiguration{}
+		for _, version := range []string{"a", "b", "c"} {
+			cfg.AnonymizerConfigs = append(cfg.AnonymizerConfigs, AnonymizerConfig{
+				AxcVersion: version,
+				Extends:    extends[version],
+				LogConfigs: []LogConfig{{Kind: "engine", RegexPatterns: []string{version}}},
+			})
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		extends map[string]string
+		want    []string // regexPatterns of engine in a
+		err     string
+	}{
+		{"chain", map[string]string{"a": "b", "b": "c"}, []string{"c", "b", "a"}, ""},
+		{"self", map[string]string{"a": "a"}, nil, "cyclic extends: a -> a"},
+		{"cycle", map[string]string{"a": "b", "b": "c", "c": "a"}, nil, "cyclic extends: a -> b -> c -> a"},
+		{"cycle below", map[string]string{"a": "b", "b": "c", "c": "b"}, nil, "cyclic extends: a -> b -> c -> b"},
+		{"missing base", map[string]string{"a": "d"}, nil, "no config found for version d"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := profiles(tt.extends).resolveAnonymizerConfig("a", nil)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := resolved.LogConfigs[0].RegexPatterns; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: regexPatterns = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
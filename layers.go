@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers
+//
+// LoadConfig accepts several files; each file may include further files with
+// include:, resolved relative to the including file. Included files are loaded
+// first, in order, and the including file is layered on top of them; files given
+// on the command line are layered in the order given. When two layers define a
+// profile with the same axcVersion:
+//
+//   - extends and versionDetection of the later layer win if set
+//   - allowlist and denylist of the later layer replace the earlier ones
+//   - kinds are merged by name: patterns are appended unless already present,
+//     paths, allowlist and denylist of the later layer replace the earlier ones
+//   - a kind with override: true or remove: true replaces the earlier kind
+//   - named patterns and categories of the later layer replace those of the same name
+//
+// Profiles only in one layer are kept as they are.
+
+// patternSource is the key of the file a pattern was first defined in.
+func patternSource(version, kind, field, pattern string) string {
+	return strings.Join([]string{version, kind, field, pattern}, "\x00")
+}
+
+// loadConfigFile loads a single configuration file together with its includes.
+//
+// Inputs:
+//   - path (string): The path to the configuration file.
+//   - visiting ([]string): The absolute paths of the files including this one, used to detect cycles.
+//
+// Outputs:
+//   - *AnonymizerConfiguration: The configuration layered on top of its includes.
+//   - error: An error if a file cannot be read or parsed, or includes form a cycle.
+func loadConfigFile(path string, visiting []string) (*AnonymizerConfiguration, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range visiting {
+		if v == absPath {
+			return nil, fmt.Errorf("cyclic include: %s -> %s", strings.Join(visiting, " -> "), absPath)
+		}
+	}
+
+	yamlFile, err := os.ReadFile(absPath)
+	if err != nil {
+		fmt.Println("Error reading YAML file:", err)
+		return nil, err
+	}
+
+	return loadConfigData(path, absPath, yamlFile, visiting)
+}
+
+// loadConfigData parses the content of a configuration file and loads its includes.
+//
+// Inputs:
+//   - path (string): The path of the configuration, used for the decoder, messages and sources.
+//   - absPath (string): The absolute path includes are resolved against.
+//   - yamlFile ([]byte): The content of the configuration.
+//   - visiting ([]string): The absolute paths of the files including this one, used to detect cycles.
+//
+// Outputs:
+//   - *AnonymizerConfiguration: The configuration layered on top of its includes.
+//   - error: An error if the content or an include cannot be parsed, or includes form a cycle.
+func loadConfigData(path, absPath string, yamlFile []byte, visiting []string) (*AnonymizerConfiguration, error) {
+	var cfg = new(AnonymizerConfiguration)
+	node, err := parseConfigNode(path, yamlFile)
+	if err == nil && node.Kind != 0 {
+		if cfg.secrets, err = substituteReferences(node, path); err != nil {
+			return nil, err
+		}
+		if err = checkKnownFields(node, reflect.TypeOf(cfg), path); err != nil {
+			return nil, err
+		}
+		err = node.Decode(cfg)
+	}
+	if err != nil {
+		fmt.Println("Error parsing config:", err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.recordSources(path)
+	cfg.resolveDenylistFiles(filepath.Dir(absPath))
+
+	layered := &AnonymizerConfiguration{}
+	for _, include := range cfg.Includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(absPath), include)
+		}
+
+		included, err := loadConfigFile(include, append(visiting, absPath))
+		if err != nil {
+			return nil, err
+		}
+		layered = layerConfiguration(layered, included)
+	}
+
+	return layerConfiguration(layered, cfg), nil
+}
+
+// parseConfigNode parses a configuration file with the decoder matching its
+// extension: .toml files are TOML, everything else, including .json, is YAML,
+// of which JSON is a subset.
+//
+// Inputs:
+//   - path (string): The path to the configuration file.
+//   - data ([]byte): The content of the file.
+//
+// Outputs:
+//   - *yaml.Node: The parsed document, of kind 0 if the file is empty.
+//   - error: An error if the file cannot be parsed.
+func parseConfigNode(path string, data []byte) (*yaml.Node, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return parseTOML(string(data))
+	default:
+		var node yaml.Node
+		err := yaml.Unmarshal(data, &node)
+		return &node, err
+	}
+}
+
+// recordSources remembers path as the source of every pattern of the configuration.
+func (cfg *AnonymizerConfiguration) recordSources(path string) {
+	cfg.sources = map[string]string{}
+
+	for name := range cfg.Patterns {
+		cfg.sources[patternSource("", "", "patterns", name)] = path
+	}
+
+	for _, anonymizerCfg := range cfg.AnonymizerConfigs {
+		for _, logCfg := range anonymizerCfg.LogConfigs {
+			fields := map[string][]string{
+				"namingPatterns":    logCfg.NamingPatterns,
+				"regexPatterns":     logCfg.RegexPatterns,
+				"contentSignatures": logCfg.ContentSignatures,
+				"patterns":          logCfg.Patterns,
+			}
+			for field, patterns := range fields {
+				for _, pattern := range patterns {
+					cfg.sources[patternSource(anonymizerCfg.AxcVersion, logCfg.Kind, field, pattern)] = path
+				}
+			}
+		}
+	}
+}
+
+// layerConfiguration layers overlay on top of base following the rules above.
+//
+// Inputs:
+//   - base (*AnonymizerConfiguration): The earlier layer.
+//   - overlay (*AnonymizerConfiguration): The later layer.
+//
+// Outputs:
+//   - *AnonymizerConfiguration: The layered configuration.
+func layerConfiguration(base, overlay *AnonymizerConfiguration) *AnonymizerConfiguration {
+	layered := &AnonymizerConfiguration{
+		Patterns:          map[string]PatternDefinition{},
+		Categories:        map[string]string{},
+		AnonymizerConfigs: append([]AnonymizerConfig{}, base.AnonymizerConfigs...),
+		sources:           map[string]string{},
+		secrets:           append(append([]string{}, base.secrets...), overlay.secrets...),
+	}
+
+	for key, source := range overlay.sources {
+		layered.sources[key] = source
+	}
+	// the earliest layer defining a pattern is its source
+	for key, source := range base.sources {
+		layered.sources[key] = source
+	}
+
+	for name, definition := range base.Patterns {
+		layered.Patterns[name] = definition
+	}
+	for name, category := range base.Categories {
+		layered.Categories[name] = category
+	}
+	for name, category := range overlay.Categories {
+		layered.Categories[name] = category
+	}
+	for name, definition := range overlay.Patterns {
+		layered.Patterns[name] = definition
+		layered.sources[patternSource("", "", "patterns", name)] = overlay.sources[patternSource("", "", "patterns", name)]
+	}
+
+	for _, profile := range overlay.AnonymizerConfigs {
+		index := -1
+		for i := range layered.AnonymizerConfigs {
+			if layered.AnonymizerConfigs[i].AxcVersion == profile.AxcVersion {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			layered.AnonymizerConfigs = append(layered.AnonymizerConfigs, profile)
+			continue
+		}
+
+		layered.AnonymizerConfigs[index] = layerAnonymizerConfig(layered.AnonymizerConfigs[index], profile)
+	}
+
+	return layered
+}
+
+// layerAnonymizerConfig layers two definitions of the same profile. Unlike
+// mergeAnonymizerConfig it keeps extends and the override and remove settings
+// of kinds, as they still apply when the profile is resolved.
+func layerAnonymizerConfig(base, overlay AnonymizerConfig) AnonymizerConfig {
+	layered := base
+	layered.LogConfigs = append([]LogConfig{}, base.LogConfigs...)
+
+	if overlay.Extends != "" {
+		layered.Extends = overlay.Extends
+	}
+	if len(overlay.VersionDetection) > 0 {
+		layered.VersionDetection = overlay.VersionDetection
+	}
+	if overlay.Allowlist != nil {
+		layered.Allowlist = overlay.Allowlist
+	}
+	if overlay.Denylist != nil {
+		layered.Denylist = overlay.Denylist
+	}
+
+	for _, logCfg := range overlay.LogConfigs {
+		index := -1
+		for i := range layered.LogConfigs {
+			if layered.LogConfigs[i].Kind == logCfg.Kind {
+				index = i
+				break
+			}
+		}
+
+		switch {
+		case index < 0:
+			layered.LogConfigs = append(layered.LogConfigs, logCfg)
+		case logCfg.Override || logCfg.Remove:
+			layered.LogConfigs[index] = logCfg
+		default:
+			merged := mergeLogConfig(layered.LogConfigs[index], logCfg)
+			merged.Override = layered.LogConfigs[index].Override
+			merged.RemoveNamingPatterns = mergePatterns(layered.LogConfigs[index].RemoveNamingPatterns, logCfg.RemoveNamingPatterns, nil)
+			merged.RemoveRegexPatterns = mergePatterns(layered.LogConfigs[index].RemoveRegexPatterns, logCfg.RemoveRegexPatterns, nil)
+			layered.LogConfigs[index] = merged
+		}
+	}
+
+	return layered
+}
+
+// GetPatternSource returns the configuration file a pattern of a resolved
+// profile was defined in, following the extends chain of the profile.
+//
+// Inputs:
+//   - version (string): The axcVersion of the profile.
+//   - kind (string): The kind the pattern belongs to.
+//   - field (string): namingPatterns, regexPatterns, contentSignatures or patterns.
+//   - pattern (string): The pattern.
+//
+// Outputs:
+//   - string: The source file, "" if unknown.
+func (cfg *AnonymizerConfiguration) GetPatternSource(version, kind, field, pattern string) string {
+	seen := map[string]bool{}
+	for version != "" && !seen[version] {
+		seen[version] = true
+
+		if source, ok := cfg.sources[patternSource(version, kind, field, pattern)]; ok {
+			return source
+		}
+
+		next := ""
+		for _, profile := range cfg.AnonymizerConfigs {
+			if profile.AxcVersion == version {
+				next = profile.Extends
+				break
+			}
+		}
+		version = next
+	}
+
+	return ""
+}
+
+// EffectiveConfigNode builds the resolved view of every profile as a YAML node,
+// with the source file of each pattern as a line comment and resolved
+// references redacted.
+//
+// Outputs:
+//   - *yaml.Node: The effective configuration.
+//   - error: An error if a profile cannot be resolved.
+func (cfg *AnonymizerConfiguration) EffectiveConfigNode() (*yaml.Node, error) {
+	profiles := &yaml.Node{Kind: yaml.SequenceNode}
+
+	for _, profile := range cfg.AnonymizerConfigs {
+		resolved, err := cfg.GetAnonymizerConfigByAxcVersion(profile.AxcVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		node := &yaml.Node{}
+		if err := node.Encode(resolved); err != nil {
+			return nil, err
+		}
+
+		for _, logNode := range mappingValue(node, "logs").Content {
+			kind := mappingValue(logNode, "kind").Value
+			for _, field := range []string{"namingPatterns", "regexPatterns", "contentSignatures", "patterns"} {
+				for _, patternNode := range mappingValue(logNode, field).Content {
+					if source := cfg.GetPatternSource(resolved.AxcVersion, kind, field, patternNode.Value); source != "" {
+						patternNode.LineComment = source
+					}
+				}
+			}
+		}
+
+		profiles.Content = append(profiles.Content, node)
+	}
+
+	library := &yaml.Node{}
+	if err := library.Encode(cfg.Patterns); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(library.Content); i += 2 {
+		library.Content[i].LineComment = cfg.sources[patternSource("", "", "patterns", library.Content[i].Value)]
+	}
+
+	categories := &yaml.Node{}
+	if err := categories.Encode(cfg.Categories); err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "patterns"},
+			library,
+			{Kind: yaml.ScalarNode, Value: "categories"},
+			categories,
+			{Kind: yaml.ScalarNode, Value: "anonymizer"},
+			profiles,
+		},
+	}
+	cfg.redactNode(node)
+
+	return node, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or an empty node.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return &yaml.Node{}
+}
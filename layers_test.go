@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfigs writes configuration files named after the keys of files to a temporary directory.
+func writeConfigs(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigLayersIncludesInOrder(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"a.yaml": `
+patterns:
+  id: {regex: 'a'}
+anonymizer:
+  - axcVersion: default
+    allowlist: {values: [a]}
+    logs:
+      - kind: engine
+        regexPatterns: ['A', 'shared']
+`,
+		"b.yaml": `
+patterns:
+  id: {regex: 'b'}
+anonymizer:
+  - axcVersion: default
+    allowlist: {values: [b]}
+    logs:
+      - kind: engine
+        regexPatterns: ['shared', 'B']
+`,
+		"main.yaml": `
+include: [a.yaml, b.yaml]
+anonymizer:
+  - axcVersion: default
+    logs:
+      - kind: engine
+        regexPatterns: ['C']
+`,
+		"site.yaml": `
+anonymizer:
+  - axcVersion: default
+    logs:
+      - kind: engine
+        regexPatterns: ['D']
+  - axcVersion: v22.0
+    extends: default
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(dir, "main.yaml"), filepath.Join(dir, "site.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.AnonymizerConfigs) != 2 || cfg.AnonymizerConfigs[0].AxcVersion != "default" || cfg.AnonymizerConfigs[1].AxcVersion != "v22.0" {
+		t.Fatalf("profiles = %+v, want default and v22.0", cfg.AnonymizerConfigs)
+	}
+
+	profile := cfg.AnonymizerConfigs[0]
+	if got, want := profile.LogConfigs[0].RegexPatterns, []string{"A", "shared", "B", "C", "D"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("regexPatterns = %q, want %q", got, want)
+	}
+	if got := profile.Allowlist.Values; !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("allowlist = %q, want the one of the later include", got)
+	}
+	if got := cfg.Patterns["id"].Regex; got != "b" {
+		t.Errorf("pattern id = %q, want the one of the later include", got)
+	}
+
+	// the earliest layer defining a pattern is its source
+	if got := cfg.GetPatternSource("default", "engine", "regexPatterns", "shared"); got != filepath.Join(dir, "a.yaml") {
+		t.Errorf("source of shared = %q, want a.yaml", got)
+	}
+	if got := cfg.GetPatternSource("v22.0", "engine", "regexPatterns", "D"); got != filepath.Join(dir, "site.yaml") {
+		t.Errorf("source of D = %q, want site.yaml", got)
+	}
+}
+
+func TestLoadConfigCyclicInclude(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"self", map[string]string{"main.yaml": "include: [main.yaml]\nanonymizer: []\n"}},
+		{"two files", map[string]string{
+			"main.yaml": "include: [a.yaml]\nanonymizer: []\n",
+			"a.yaml":    "include: [b.yaml]\nanonymizer: []\n",
+			"b.yaml":    "include: [a.yaml]\nanonymizer: []\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		dir := writeConfigs(t, tt.files)
+		_, err := LoadConfig(filepath.Join(dir, "main.yaml"))
+		if err == nil || !strings.Contains(err.Error(), "cyclic include") {
+			t.Errorf("%s: LoadConfig error = %v, want a cyclic include", tt.name, err)
+		}
+	}
+
+	// including the same file twice is not a cycle
+	dir := writeConfigs(t, map[string]string{
+		"main.yaml":   "include: [common.yaml, common.yaml]\nanonymizer: []\n",
+		"common.yaml": "anonymizer: []\n",
+	})
+	if _, err := LoadConfig(filepath.Join(dir, "main.yaml")); err != nil {
+		t.Errorf("diamond include: %v", err)
+	}
+}
+
+func TestLayerAnonymizerConfig(t *testing.T) {
+	base := AnonymizerConfig{
+		AxcVersion:       "v22.0",
+		Extends:          "default",
+		VersionDetection: []string{`v(22\.0)`},
+		Allowlist:        &AllowlistConfig{Values: []string{"base"}},
+		LogConfigs: []LogConfig{
+			{Kind: "engine", RegexPatterns: []string{"A"}, Override: true, RemoveRegexPatterns: []string{"X"}},
+			{Kind: "service", RegexPatterns: []string{"S"}},
+			{Kind: "gateway", RegexPatterns: []string{"G"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		overlay AnonymizerConfig
+		check   func(t *testing.T, layered AnonymizerConfig)
+	}{
+		{"unset settings are kept", AnonymizerConfig{AxcVersion: "v22.0"}, func(t *testing.T, layered AnonymizerConfig) {
+			if layered.Extends != "default" || len(layered.VersionDetection) != 1 || layered.Allowlist.Values[0] != "base" {
+				t.Errorf("layered = %+v, want the settings of base", layered)
+			}
+		}},
+		{"set settings win", AnonymizerConfig{AxcVersion: "v22.0", Extends: "v21", VersionDetection: []string{"x"}, Allowlist: &AllowlistConfig{}}, func(t *testing.T, layered AnonymizerConfig) {
+			if layered.Extends != "v21" || layered.VersionDetection[0] != "x" || len(layered.Allowlist.Values) != 0 {
+				t.Errorf("layered = %+v, want the settings of the overlay", layered)
+			}
+		}},
+		{"kinds merge and keep override and removals", AnonymizerConfig{LogConfigs: []LogConfig{
+			{Kind: "engine", RegexPatterns: []string{"A", "B"}, RemoveRegexPatterns: []string{"Y"}},
+		}}, func(t *testing.T, layered AnonymizerConfig) {
+			engine := layered.LogConfigs[0]
+			if !reflect.DeepEqual(engine.RegexPatterns, []string{"A", "B"}) || !engine.Override ||
+				!reflect.DeepEqual(engine.RemoveRegexPatterns, []string{"X", "Y"}) {
+				t.Errorf("engine = %+v, want merged patterns, override and both removals", engine)
+			}
+		}},
+		{"override and remove replace the kind", AnonymizerConfig{LogConfigs: []LogConfig{
+			{Kind: "service", RegexPatterns: []string{"T"}, Override: true},
+			{Kind: "gateway", Remove: true},
+		}}, func(t *testing.T, layered AnonymizerConfig) {
+			service, gateway := layered.LogConfigs[1], layered.LogConfigs[2]
+			if !reflect.DeepEqual(service.RegexPatterns, []string{"T"}) || !service.Override {
+				t.Errorf("service = %+v, want the overriding kind", service)
+			}
+			// the removal still applies when the profile is resolved
+			if !gateway.Remove || len(gateway.RegexPatterns) != 0 {
+				t.Errorf("gateway = %+v, want the removing kind", gateway)
+			}
+		}},
+		{"new kinds are appended", AnonymizerConfig{LogConfigs: []LogConfig{{Kind: "new"}}}, func(t *testing.T, layered AnonymizerConfig) {
+			if len(layered.LogConfigs) != 4 || layered.LogConfigs[3].Kind != "new" {
+				t.Errorf("kinds = %+v, want new appended", layered.LogConfigs)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, layerAnonymizerConfig(base, tt.overlay))
+		})
+	}
+
+	if len(base.LogConfigs) != 3 || !reflect.DeepEqual(base.LogConfigs[0].RegexPatterns, []string{"A"}) {
+		t.Errorf("layering modified base: %+v", base.LogConfigs)
+	}
+}
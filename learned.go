@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	PROPAGATE_OFF  = "off"  // only redact values where a regex pattern captures them
+	PROPAGATE_FILE = "file" // redact captured values everywhere in the same file
+	PROPAGATE_RUN  = "run"  // redact captured values in every file processed afterwards
+
+	MIN_LEARNED_LENGTH = 3   // shorter captures are too ambiguous to redact everywhere
+	LEARNED_BATCH_SIZE = 256 // new values matched by a small matcher before the full one is rebuilt
+)
+
+// LearnedTerms is the set of sensitive values captured so far. It is safe for
+// concurrent use. New values are compiled into a small matcher layered over the
+// matcher of the earlier ones, which is only rebuilt once LEARNED_BATCH_SIZE
+// values are pending, so learning n values does not compile n automatons of
+// up to n terms each.
+type LearnedTerms struct {
+	mu      sync.Mutex
+	terms   map[string]string // lower-cased value -> value
+	pending []string          // values added since base was built
+	base    *TermMatcher      // all values but the pending ones
+	matcher *TermMatcher
+	dirty   bool
+}
+
+func NewLearnedTerms() *LearnedTerms {
+	return &LearnedTerms{terms: map[string]string{}}
+}
+
+// Add records a captured value. Values shorter than MIN_LEARNED_LENGTH are ignored.
+//
+// Parameters:
+//   - value (string): The captured value.
+func (lt *LearnedTerms) Add(value string) {
+	if lt == nil {
+		return
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) < MIN_LEARNED_LENGTH {
+		return
+	}
+
+	key := strings.ToLower(value)
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if _, ok := lt.terms[key]; !ok {
+		lt.terms[key] = value
+		lt.pending = append(lt.pending, value)
+		lt.dirty = true
+	}
+}
+
+// Merge adds all values of other.
+//
+// Parameters:
+//   - other (*LearnedTerms): The values to add.
+func (lt *LearnedTerms) Merge(other *LearnedTerms) {
+	for _, value := range other.Values() {
+		lt.Add(value)
+	}
+}
+
+// Values returns the learned values.
+func (lt *LearnedTerms) Values() []string {
+	if lt == nil {
+		return nil
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	values := make([]string, 0, len(lt.terms))
+	for _, key := range sortedKeys(lt.terms) {
+		values = append(values, lt.terms[key])
+	}
+	return values
+}
+
+// Matcher returns a whole-word TermMatcher over the learned values.
+//
+// Returns:
+//   - *TermMatcher: The matcher, or nil if nothing was learned.
+func (lt *LearnedTerms) Matcher() *TermMatcher {
+	if lt == nil {
+		return nil
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if !lt.dirty {
+		return lt.matcher
+	}
+
+	if len(lt.pending) >= LEARNED_BATCH_SIZE {
+		values := make([]string, 0, len(lt.terms))
+		for _, value := range lt.terms {
+			values = append(values, value)
+		}
+		lt.base = NewTermMatcher(values, true)
+		lt.pending = nil
+		lt.matcher = lt.base
+	} else {
+		lt.matcher = layered(lt.pending, lt.base)
+	}
+	lt.dirty = false
+
+	return lt.matcher
+}
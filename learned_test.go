@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLearnedTermsMatcherRebuildsInBatches(t *testing.T) {
+	lt := NewLearnedTerms()
+	if lt.Matcher() != nil {
+		t.Fatal("matcher of no values is not nil")
+	}
+
+	var base *TermMatcher
+	rebuilds := 0
+	for i := 0; i < 3*LEARNED_BATCH_SIZE; i++ {
+		value := fmt.Sprintf("user%04d", i)
+		lt.Add(value)
+
+		m := lt.Matcher()
+		if got, count := m.Replace("by "+value, func(string) string { return "[X]" }); got != "by [X]" || count != 1 {
+			t.Fatalf("value %d: Replace = %q, %d, want every learned value redacted", i, got, count)
+		}
+		if m.Len() != i+1 {
+			t.Fatalf("value %d: matcher has %d terms, want %d", i, m.Len(), i+1)
+		}
+		if lt.base != base {
+			base = lt.base
+			rebuilds++
+		}
+	}
+
+	if rebuilds != 3 {
+		t.Errorf("full matcher rebuilt %d times for %d values, want 3", rebuilds, 3*LEARNED_BATCH_SIZE)
+	}
+	if got, _ := lt.Matcher().Replace("user0000 user0767", func(string) string { return "[X]" }); got != "[X] [X]" {
+		t.Errorf("Replace after rebuilds = %q", got)
+	}
+}
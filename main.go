@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -11,12 +13,30 @@ import (
 const (
 	DEFAULT_KIND        = "*"
 	DEFAULT_CONFIG      = "config.yaml"
+	DEFAULT_GOLDEN_DIR  = "testdata/golden"
 	DEFAULT_AXC_VERSION = "default"
+	AUTO_AXC_VERSION    = "auto" // detect the axcVersion per log file, falling back to DEFAULT_AXC_VERSION; also done without --axcVersion
 	DEFAULT_OBFUSCATION = "[*CONFIDENTIAL*]"
 	DEFAULT_WORKERCOUNT = 2
+
+	UNKNOWN_SKIP       = "skip"       // warn and leave unrecognised files out
+	UNKNOWN_FAIL       = "fail"       // fail the run before any file is processed
+	UNKNOWN_GENERIC    = "generic"    // anonymize with the generic kind
+	UNKNOWN_QUARANTINE = "quarantine" // leave out and list in QUARANTINE_MANIFEST in the output directory
+
+	QUARANTINE_MANIFEST = "quarantine.txt"
+
+	DEFAULT_SNIFF_LINES = 50
+	NAMING_SCORE        = 2 // evidence of a matching naming pattern
+	CONTENT_SCORE       = 1 // evidence of each matching content signature
+
+	DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+	ABORT_GRACE_PERIOD       = 5 * time.Second // time for aborted workers to remove their partial output
 )
 
+// GlobalConfig is the axcVersion profile selected for the run, GlobalConfiguration holds all profiles.
 var GlobalConfig *AnonymizerConfig
+var GlobalConfiguration *AnonymizerConfiguration
 
 func main() {
 	app := &cli.App{
@@ -24,16 +44,16 @@ func main() {
 		Version: "0.1-alpha",
 		Usage:   "Axcelerate Log Anonymizer",
 		Flags: []cli.Flag{
-			&cli.StringFlag{
+			&cli.StringSliceFlag{
 				Name:    "config",
 				Aliases: []string{"c"},
-				Usage:   "Anonymizer configuration file path",
-				Value:   DEFAULT_CONFIG,
+				Usage:   "Anonymizer configuration file path, repeat to layer several files; the built-in configuration is used if omitted and config.yaml does not exist",
+				Value:   cli.NewStringSlice(DEFAULT_CONFIG),
 			},
 			&cli.StringFlag{
 				Name:    "axcVersion",
 				Aliases: []string{"x"},
-				Usage:   "axcelerate version",
+				Usage:   "axcelerate version; if omitted or auto it is detected from each log file, falling back to default",
 				Value:   DEFAULT_AXC_VERSION,
 			},
 			&cli.StringFlag{
@@ -67,18 +87,39 @@ func main() {
 
 			log.Debug().Msgf("axcVersion: %+v", c.String("axcVersion"))
 
-			yamlCfg, err := LoadConfig(c.String("config"))
+			// these commands do not depend on the configuration
+			if command := c.Args().First(); command == Schema.Name || command == InitConfig.Name {
+				return nil
+			}
+
+			var yamlCfg *AnonymizerConfiguration
+			if _, statErr := os.Stat(DEFAULT_CONFIG); !c.IsSet("config") && os.IsNotExist(statErr) {
+				log.Debug().Msgf("%s not found, using the built-in configuration", DEFAULT_CONFIG)
+				yamlCfg, err = LoadEmbeddedConfig()
+			} else {
+				yamlCfg, err = LoadConfig(c.StringSlice("config")...)
+			}
 			if err != nil {
-				return err
+				return withExitCode(EXIT_CONFIG_ERROR, err)
+			}
+			log.Debug().Msgf("yaml Config: %s", yamlCfg.Redact(fmt.Sprintf("%+v", yamlCfg)))
+
+			GlobalConfiguration = yamlCfg
+
+			axcVersion := c.String("axcVersion")
+			if axcVersion == AUTO_AXC_VERSION {
+				if !yamlCfg.hasVersionDetection() {
+					log.Warn().Msgf("no versionDetection configured, using axcVersion %s", DEFAULT_AXC_VERSION)
+				}
+				axcVersion = DEFAULT_AXC_VERSION
 			}
-			log.Debug().Msgf("yaml Config: %+v", yamlCfg)
 
 			// DO NOT USE := to set global variable due to variable shawdowing
-			GlobalConfig, err = yamlCfg.GetAnonymizerConfigByAxcVersion(c.String("axcVersion"))
+			GlobalConfig, err = yamlCfg.GetAnonymizerConfigByAxcVersion(axcVersion)
 			if err != nil {
-				return err
+				return withExitCode(EXIT_CONFIG_ERROR, err)
 			}
-			log.Debug().Msgf("GlobalConfig: %+v", GlobalConfig)
+			log.Debug().Msgf("GlobalConfig: %s", yamlCfg.Redact(fmt.Sprintf("%+v", GlobalConfig)))
 
 			return nil
 		},
@@ -86,5 +127,6 @@ func main() {
 
 	if err := app.Run(os.Args); err != nil {
 		log.Error().Msg(err.Error())
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TEMP_FILE_MARKER is part of the name of every temporary output file. Output is
+// written to a hidden temporary file in the target directory and only renamed
+// into place once the whole log file was anonymized.
+const TEMP_FILE_MARKER = ".anonymizer-tmp-"
+
+// isTempFile reports whether baseName is a temporary output file.
+func isTempFile(baseName string) bool {
+	return strings.HasPrefix(baseName, ".") && strings.Contains(baseName, TEMP_FILE_MARKER)
+}
+
+// tempFiles keeps track of the temporary output files currently being written.
+type tempFiles struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func newTempFiles() *tempFiles {
+	return &tempFiles{paths: map[string]bool{}}
+}
+
+// create opens a new temporary file next to target. os.CreateTemp creates it
+// readable by the owner only, so it is given mode before it is renamed into place.
+//
+// Parameters:
+//   - target (string): The final output file name.
+//   - mode (os.FileMode): The permissions of the output file, usually those of the log file.
+//
+// Returns:
+//   - *os.File: The temporary file.
+//   - error: Any error encountered while creating the file.
+func (t *tempFiles) create(target string, mode os.FileMode) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+TEMP_FILE_MARKER+"*")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(mode.Perm()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paths[f.Name()] = true
+
+	return f, nil
+}
+
+// commit closes the temporary file f and renames it to target.
+//
+// Parameters:
+//   - f (*os.File): The temporary file returned by create.
+//   - target (string): The final output file name.
+//   - noOverwrite (bool): Fail instead of replacing an existing target.
+//
+// Returns:
+//   - error: Any error encountered; the temporary file is removed in that case.
+func (t *tempFiles) commit(f *os.File, target string, noOverwrite bool) error {
+	if err := f.Close(); err != nil {
+		t.discard(f)
+		return err
+	}
+
+	if noOverwrite {
+		if _, err := os.Stat(target); err == nil {
+			t.discard(f)
+			return fmt.Errorf("output file %s already exists", target)
+		}
+	}
+
+	if err := os.Rename(f.Name(), target); err != nil {
+		t.discard(f)
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.paths, f.Name())
+
+	return nil
+}
+
+// discard closes and removes the temporary file f.
+func (t *tempFiles) discard(f *os.File) {
+	f.Close()
+	t.remove(f.Name())
+}
+
+func (t *tempFiles) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Error().Msgf("removing temporary file: %s", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.paths, path)
+}
+
+// removeAll removes every temporary file still being written.
+func (t *tempFiles) removeAll() {
+	t.mu.Lock()
+	paths := sortedKeys(t.paths)
+	t.mu.Unlock()
+
+	for _, path := range paths {
+		t.remove(path)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	PATH_SEGMENT_USER   = "user"
+	PATH_SEGMENT_SERVER = "server"
+	PATH_SEGMENT_SHARE  = "share"
+	PATH_SEGMENT_CASE   = "case"
+)
+
+// pathRegex finds UNC paths (\\server\share\...), drive paths (C:\...) and
+// absolute POSIX paths (/home/jdoe/...). Whitespace, quotes and list
+// separators end a path, so class paths are split into their entries. The
+// multi-word Windows directories of userParentDirectories and systemDirectories
+// are the exception when followed by a separator, as in C:\Documents and Settings\jdoe.
+var pathRegex = func() *regexp.Regexp {
+	segment := `[^\\/\s'"<>|;,]*`
+	windowsSegments := `(?:(?i:` + multiWordDirectories() + `)[\\/]|` + segment + `[\\/])*` + segment
+
+	return regexp.MustCompile(`\\\\[^\\\s'"<>|;,]+\\` + windowsSegments +
+		`|[A-Za-z]:[\\/]` + windowsSegments +
+		`|/(?:[^/\s'"<>|;,:]+/)+[^/\s'"<>|;,:]*`)
+}()
+
+// multiWordDirectories returns an alternation of the directory names containing
+// spaces, longest first so Program Files (x86) wins over Program Files.
+func multiWordDirectories() string {
+	var names []string
+	for _, dirs := range []map[string]bool{userParentDirectories, systemDirectories} {
+		for name := range dirs {
+			if strings.Contains(name, " ") {
+				names = append(names, regexp.QuoteMeta(name))
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return strings.Join(names, "|")
+}
+
+// userParentDirectories are the directories whose child is a user profile.
+var userParentDirectories = map[string]bool{
+	"users":                  true,
+	"documents and settings": true,
+	"home":                   true,
+}
+
+// systemDirectories are well-known directory names that are never pseudonymized.
+var systemDirectories = map[string]bool{
+	"windows":             true,
+	"system32":            true,
+	"syswow64":            true,
+	"program files":       true,
+	"program files (x86)": true,
+	"programdata":         true,
+	"appdata":             true,
+	"local":               true,
+	"locallow":            true,
+	"roaming":             true,
+	"temp":                true,
+	"tmp":                 true,
+	"users":               true,
+	"public":              true,
+	"default":             true,
+	"all users":           true,
+	"desktop":             true,
+	"documents":           true,
+	"downloads":           true,
+	"home":                true,
+	"root":                true,
+	"usr":                 true,
+	"bin":                 true,
+	"lib":                 true,
+	"etc":                 true,
+	"opt":                 true,
+	"var":                 true,
+	"log":                 true,
+	"logs":                true,
+	"data":                true,
+	"java":                true,
+	"jre":                 true,
+	"jdk":                 true,
+}
+
+// PathAnonymizer pseudonymizes selected segments of Windows, UNC and POSIX paths.
+type PathAnonymizer struct {
+	segments      map[string]bool
+	casePatterns  []*regexp.Regexp
+	keep          map[string]bool
+	pseudonymizer *Pseudonymizer
+}
+
+// NewPathAnonymizer builds a PathAnonymizer from a PathConfig.
+//
+// Parameters:
+//   - cfg (*PathConfig): The path anonymization settings of a log kind.
+//   - pseudonymizer (*Pseudonymizer): The source of replacement tokens.
+//
+// Returns:
+//   - *PathAnonymizer: The configured path anonymizer.
+//   - error: An error if a segment name or case folder pattern is invalid.
+func NewPathAnonymizer(cfg *PathConfig, pseudonymizer *Pseudonymizer) (*PathAnonymizer, error) {
+	pa := &PathAnonymizer{
+		segments:      map[string]bool{},
+		keep:          map[string]bool{},
+		pseudonymizer: pseudonymizer,
+	}
+
+	for _, segment := range cfg.Segments {
+		switch segment {
+		case PATH_SEGMENT_USER, PATH_SEGMENT_SERVER, PATH_SEGMENT_SHARE, PATH_SEGMENT_CASE:
+			pa.segments[segment] = true
+		default:
+			return nil, fmt.Errorf("unknown path segment %q", segment)
+		}
+	}
+
+	for _, pattern := range cfg.CaseFolderPatterns {
+		rex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid case folder pattern %q: %w", pattern, err)
+		}
+		pa.casePatterns = append(pa.casePatterns, rex)
+	}
+
+	for _, dir := range cfg.KeepDirectories {
+		pa.keep[strings.ToLower(dir)] = true
+	}
+
+	return pa, nil
+}
+
+// Anonymize replaces the configured segments of every path found in line.
+//
+// Parameters:
+//   - line: the log line to anonymize
+//
+// Returns:
+//   - The log line with path segments pseudonymized
+func (pa *PathAnonymizer) Anonymize(line string) string {
+	locs := pathRegex.FindAllStringIndex(line, -1)
+	if len(locs) == 0 {
+		return line
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range locs {
+		// a match preceded by a word character or slash is part of a URL or token
+		if line[loc[0]] != '\\' && loc[0] > 0 && isPathWordByte(line[loc[0]-1]) {
+			continue
+		}
+		sb.WriteString(line[last:loc[0]])
+		sb.WriteString(pa.anonymizePath(line[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sb.WriteString(line[last:])
+
+	return sb.String()
+}
+
+// Values returns the original values of the segments Anonymize would replace in line.
+//
+// Parameters:
+//   - line: the log line to scan
+//
+// Returns:
+//   - The sensitive path segments, without file extensions
+func (pa *PathAnonymizer) Values(line string) []string {
+	var values []string
+
+	for _, loc := range pathRegex.FindAllStringIndex(line, -1) {
+		if line[loc[0]] != '\\' && loc[0] > 0 && isPathWordByte(line[loc[0]-1]) {
+			continue
+		}
+		segments, _, _, unc := splitPath(line[loc[0]:loc[1]])
+		for i, segment := range segments {
+			if pa.categorize(segments, i, unc) == "" {
+				continue
+			}
+			if i == len(segments)-1 {
+				segment = strings.TrimSuffix(segment, segmentExt(segment))
+			}
+			values = append(values, segment)
+		}
+	}
+
+	return values
+}
+
+func isPathWordByte(b byte) bool {
+	return b == '/' || b == ':' || b == '.' || b == '_' || b == '-' ||
+		(b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// anonymizePath splits a single path into its root and segments and
+// pseudonymizes the segments selected by the configuration.
+func (pa *PathAnonymizer) anonymizePath(p string) string {
+	segments, root, sep, unc := splitPath(p)
+	windows := root != "/"
+	rest := p[len(root):]
+
+	for i, segment := range segments {
+		if category := pa.categorize(segments, i, unc); category != "" {
+			segments[i] = pa.pseudonymizeSegment(category, segment, windows, i == len(segments)-1)
+		}
+	}
+
+	// preserve a trailing separator
+	trailing := ""
+	if windows && (strings.HasSuffix(rest, `\`) || strings.HasSuffix(rest, "/")) {
+		trailing = sep
+	}
+
+	return root + strings.Join(segments, sep) + trailing
+}
+
+// splitPath splits a path into its segments, root and separator and reports
+// whether it is a UNC path.
+func splitPath(p string) (segments []string, root, sep string, unc bool) {
+	switch {
+	case strings.HasPrefix(p, `\\`):
+		root, sep, unc = `\\`, `\`, true
+	case len(p) >= 3 && p[1] == ':':
+		root, sep = p[:3], p[2:3]
+	default:
+		return strings.Split(p[1:], "/"), "/", "/", false
+	}
+
+	segments = strings.FieldsFunc(p[len(root):], func(r rune) bool { return r == '\\' || r == '/' })
+	return segments, root, sep, unc
+}
+
+// categorize returns the category of segment i, or "" if it must be kept.
+func (pa *PathAnonymizer) categorize(segments []string, i int, unc bool) string {
+	segment := segments[i]
+	lower := strings.ToLower(segment)
+
+	if unc && i == 0 {
+		if pa.segments[PATH_SEGMENT_SERVER] {
+			return PATH_SEGMENT_SERVER
+		}
+		return ""
+	}
+	if unc && i == 1 {
+		if pa.segments[PATH_SEGMENT_SHARE] {
+			return PATH_SEGMENT_SHARE
+		}
+		return ""
+	}
+
+	if segment == "" || systemDirectories[lower] || pa.keep[lower] {
+		return ""
+	}
+
+	if pa.segments[PATH_SEGMENT_USER] && i > 0 && userParentDirectories[strings.ToLower(segments[i-1])] {
+		return PATH_SEGMENT_USER
+	}
+
+	if pa.segments[PATH_SEGMENT_CASE] {
+		for _, rex := range pa.casePatterns {
+			if rex.MatchString(segment) {
+				return PATH_SEGMENT_CASE
+			}
+		}
+	}
+
+	return ""
+}
+
+// pseudonymizeSegment replaces a segment with its token, keeping the file
+// extension of the last segment. Windows segments are case-insensitive.
+func (pa *PathAnonymizer) pseudonymizeSegment(category, segment string, windows, isLast bool) string {
+	ext := ""
+	if isLast {
+		ext = segmentExt(segment)
+	}
+
+	value := strings.TrimSuffix(segment, ext)
+	if windows {
+		value = strings.ToLower(value)
+	}
+
+	return pa.pseudonymizer.Pseudonym(category, value) + ext
+}
+
+// segmentExt returns the file extension of a path segment, "" for dot files.
+func segmentExt(segment string) string {
+	ext := filepath.Ext(segment)
+	if ext == segment {
+		return ""
+	}
+	return ext
+}
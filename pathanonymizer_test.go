@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPathAnonymizerPathsWithSpaces(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`open C:\Documents and Settings\jdoe\app.log`, `open C:\Documents and Settings\<USER_1>\app.log`},
+		{`open c:\documents and settings\jdoe`, `open c:\documents and settings\<USER_1>`},
+		{`java C:\Program Files\Java\bin\java.exe -jar x`, `java C:\Program Files\Java\bin\java.exe -jar x`},
+		{`cwd C:\Program Files (x86)\AXC\bin`, `cwd C:\Program Files (x86)\AXC\bin`},
+		{`from \\fs01\c$\Documents and Settings\jdoe\x.txt`, `from \\fs01\c$\Documents and Settings\<USER_1>\x.txt`},
+		{`C:\Users\All Users\app.ini`, `C:\Users\All Users\app.ini`},
+		// a multi-word directory is only recognised when a separator follows
+		{`saved to C:\Program Files are read-only`, `saved to C:\Program Files are read-only`},
+		{`C:\Users\jdoe and Settings`, `C:\Users\<USER_1> and Settings`},
+		{`/home/jdoe/My Documents/x`, `/home/<USER_1>/My Documents/x`},
+	}
+
+	for _, tt := range tests {
+		pa, err := NewPathAnonymizer(&PathConfig{Segments: []string{PATH_SEGMENT_USER}}, NewPseudonymizer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pa.Anonymize(tt.line); got != tt.want {
+			t.Errorf("Anonymize(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
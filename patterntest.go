@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// readSampleLines collects sample lines from the arguments, a file or, if neither
+// is given, the reader.
+//
+// Parameters:
+//   - args ([]string): Sample lines given on the command line.
+//   - path (string): A file of sample lines, "" for none.
+//   - stdin (io.Reader): Read when there are no arguments and no file.
+//
+// Returns:
+//   - []string: The sample lines.
+//   - error: Any error encountered while reading the file or the reader.
+func readSampleLines(args []string, path string, stdin io.Reader) ([]string, error) {
+	lines := append([]string{}, args...)
+
+	var r io.Reader
+	switch {
+	case path != "":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	case len(args) == 0:
+		r = stdin
+	default:
+		return lines, nil
+	}
+
+	fs := bufio.NewScanner(r)
+	fs.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for fs.Scan() {
+		lines = append(lines, fs.Text())
+	}
+
+	return lines, fs.Err()
+}
+
+// selectPattern returns the pattern testPattern works with: regex if given,
+// otherwise the index-th (1-based) regex pattern of kind as numbered by
+// listRegexPatterns.
+//
+// Parameters:
+//   - cfg (*AnonymizerConfig): The axcVersion profile.
+//   - regex (string): An ad-hoc regex, "" to use a configured pattern.
+//   - kind (string): The log kind.
+//   - index (int): The number of the configured pattern.
+//
+// Returns:
+//   - Pattern: The selected pattern with its categories.
+//   - error: An error if the regex is invalid or there is no such pattern.
+func selectPattern(cfg *AnonymizerConfig, regex, kind string, index int) (Pattern, error) {
+	if regex != "" {
+		rex, err := regexp.Compile(regex)
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		return cfg.withCategories(Pattern{Kind: kind, Pattern: regex, Regex: rex}), nil
+	}
+
+	patterns, err := cfg.GetRegexPatterns(kind)
+	if err != nil {
+		return Pattern{}, err
+	}
+	if index < 1 || index > len(patterns) {
+		return Pattern{}, fmt.Errorf("no regex pattern %d for kind %s, see listRegexPatterns", index, kind)
+	}
+	return patterns[index-1], nil
+}
+
+// TestPattern prints for every sample line whether pattern matched, each capture
+// group with its category, byte offsets and allowlist decision, and the line as
+// obfuscate anonymizes it with this pattern alone.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - pattern (Pattern): The pattern to test.
+//   - allowlist (*Allowlist): The allowlist of the kind, may be nil.
+//   - lines ([]string): The sample lines.
+//
+// Returns:
+//   - int: The number of lines the pattern matched.
+func (s *Scheduler) TestPattern(w io.Writer, pattern Pattern, allowlist *Allowlist, lines []string) int {
+	rules := &ruleSet{kind: pattern.Kind, regexes: []Pattern{pattern}, allowlist: allowlist}
+
+	matched := 0
+	for n, line := range lines {
+		fmt.Fprintf(w, "line %d: %s\n", n+1, line)
+
+		if !writeGroups(w, pattern, allowlist, line) {
+			fmt.Fprintln(w, "    no match")
+			continue
+		}
+		matched++
+
+		fmt.Fprintf(w, "    result: %s\n", s.obfuscate(line, rules, nil))
+	}
+
+	fmt.Fprintf(w, "%d of %d lines matched\n", matched, len(lines))
+	return matched
+}
+
+// writeGroups prints every match of pattern in line with its capture groups,
+// their categories, byte offsets and allowlist decisions.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - pattern (Pattern): The pattern to match.
+//   - allowlist (*Allowlist): The allowlist of the kind, may be nil.
+//   - line (string): The line to match.
+//
+// Returns:
+//   - bool: true if the pattern matched.
+func writeGroups(w io.Writer, pattern Pattern, allowlist *Allowlist, line string) bool {
+	locs := pattern.Regex.FindAllStringSubmatchIndex(line, -1)
+	for _, loc := range locs {
+		fmt.Fprintf(w, "    match [%d:%d] %q\n", loc[0], loc[1], line[loc[0]:loc[1]])
+		for _, c := range patternCaptures(allowlist, line, loc) {
+			if c.start < 0 {
+				fmt.Fprintf(w, "        group %-3d%-12s %s\n", c.group, pattern.category(c.group-1), c.decision)
+				continue
+			}
+			fmt.Fprintf(w, "        group %-3d%-12s[%d:%d] %q %s\n", c.group, pattern.category(c.group-1), c.start, c.end, c.value, c.decision)
+		}
+	}
+
+	return len(locs) > 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatternReplacesCapturedSpan(t *testing.T) {
+	tests := []struct {
+		name     string
+		regex    string
+		strategy string
+		line     string
+		want     string
+	}{
+		{"value also earlier in the line", `user '(.*?)'`, "", "jdoe: user 'jdoe'", "jdoe: user '[X]'"},
+		{"value inside a longer word", `id=(\w+)`, "", "ann-joann id=ann", "ann-joann id=[X]"},
+		{"every match", `u=(\w+)`, "", "a u=ann b u=bob", "a u=[X] b u=[X]"},
+		{"token at the captured span", `to (\w+)`, STRATEGY_PSEUDONYMIZE, "jdoe sent to jdoe", "jdoe sent to <VALUE_1>"},
+		{"nested group replaced once", `login ((\w+)@\w+)`, "", "login jdoe@corp", "login [X]"},
+		{"allowlisted group kept", `(\w+) -> (\w+)`, "", "admin -> jdoe", "admin -> [X]"},
+		{"optional group not captured", `a(b)?(c)`, "", "ac", "a[X]"},
+	}
+
+	allowlist, err := NewAllowlist(&AllowlistConfig{Values: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		s := NewScheduler().WithObfuscation("[X]")
+		pattern := Pattern{Pattern: tt.regex, Regex: regexp.MustCompile(tt.regex), Strategy: tt.strategy}
+		rules := &ruleSet{regexes: []Pattern{pattern}, allowlist: allowlist}
+
+		if got := s.obfuscate(tt.line, rules, nil); got != tt.want {
+			t.Errorf("%s: obfuscate(%q) = %q, want %q", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTestPatternReportsReplacedOffsets(t *testing.T) {
+	s := NewScheduler().WithObfuscation("[X]")
+	pattern := Pattern{Pattern: `user '(.*?)'`, Regex: regexp.MustCompile(`user '(.*?)'`)}
+
+	var w bytes.Buffer
+	s.TestPattern(&w, pattern, nil, []string{"jdoe: user 'jdoe'"})
+
+	for _, want := range []string{`[12:16] "jdoe" replaced`, "result: jdoe: user '[X]'"} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("testPattern output lacks %q:\n%s", want, w.String())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Pseudonymizer hands out stable replacement tokens for sensitive values.
+// The same value in the same category always maps to the same token for the
+// lifetime of the Pseudonymizer, so it can be shared between workers.
+type Pseudonymizer struct {
+	mu       sync.Mutex
+	tokens   map[string]string
+	counters map[string]int
+}
+
+func NewPseudonymizer() *Pseudonymizer {
+	return &Pseudonymizer{
+		tokens:   map[string]string{},
+		counters: map[string]int{},
+	}
+}
+
+// Pseudonym returns the token for value within category, e.g. <USER_1>.
+//
+// Parameters:
+//   - category (string): The entity category of the value, e.g. user or server.
+//   - value (string): The sensitive value to be replaced.
+//
+// Returns:
+//   - string: The token assigned to the value.
+func (p *Pseudonymizer) Pseudonym(category, value string) string {
+	category = strings.ToUpper(category)
+	key := category + "\x00" + value
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if token, ok := p.tokens[key]; ok {
+		return token
+	}
+
+	p.counters[category]++
+	token := fmt.Sprintf("<%s_%d>", category, p.counters[category])
+	p.tokens[key] = token
+	return token
+}
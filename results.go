@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	FILE_COMPLETED   = "completed"
+	FILE_FAILED      = "failed"
+	FILE_ABORTED     = "aborted"
+	FILE_NOT_STARTED = "not started"
+	FILE_QUARANTINED = "quarantined" // unrecognised and deliberately left out
+)
+
+// FileResult is the outcome of processing one log file.
+type FileResult struct {
+	Info   logFileInfo
+	Status string
+	Err    error
+}
+
+// newFileResult derives the status of a log file from the error its worker returned.
+//
+// Parameters:
+//   - info (logFileInfo): The log file.
+//   - err (error): The error returned by the worker, nil on success.
+//
+// Returns:
+//   - FileResult: The outcome of the log file.
+func newFileResult(info logFileInfo, err error) FileResult {
+	switch {
+	case err == nil:
+		return FileResult{Info: info, Status: FILE_COMPLETED}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return FileResult{Info: info, Status: FILE_ABORTED, Err: err}
+	default:
+		return FileResult{Info: info, Status: FILE_FAILED, Err: err}
+	}
+}
+
+// printResults writes the number of files per status and lists every file
+// that did not complete.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - results ([]FileResult): The outcome of each log file.
+func printResults(w io.Writer, results []FileResult) {
+	byStatus := map[string][]FileResult{}
+	for _, result := range results {
+		byStatus[result.Status] = append(byStatus[result.Status], result)
+	}
+
+	for _, status := range []string{FILE_COMPLETED, FILE_FAILED, FILE_ABORTED, FILE_NOT_STARTED, FILE_QUARANTINED} {
+		if len(byStatus[status]) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%-16s%d\n", status, len(byStatus[status]))
+		if status == FILE_COMPLETED {
+			continue
+		}
+
+		for _, result := range byStatus[status] {
+			if result.Err != nil && status == FILE_FAILED {
+				fmt.Fprintf(w, "    %s: %s\n", result.Info.path, result.Err)
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", result.Info.path)
+		}
+	}
+}
@@ -2,26 +2,56 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type Scheduler struct {
-	path        string
-	kind        string
-	obfuscation string
-	workerCount int
+	path            string
+	kind            string
+	obfuscation     string
+	workerCount     int
+	propagate       string
+	twoPass         bool
+	outputDir       string
+	keepNames       bool
+	noOverwrite     bool
+	shutdownTimeout time.Duration
+	verify          bool
+	unknownFiles    string
+	sniffLines      int
+	autoVersion     bool
+	unrecognised    []logFileInfo // files no kind was detected for
+	pseudonymizer   *Pseudonymizer
+	learned         *LearnedTerms // values captured across the run
+	stats           *RunStats
+	coverage        *Coverage
+	temps           *tempFiles
+
+	rulesMu sync.Mutex
+	rules   map[string]*ruleSet // rule sets by axcVersion and kind, built once per run
 }
 
 func NewScheduler() *Scheduler {
-	return &Scheduler{}
+	return &Scheduler{
+		pseudonymizer: NewPseudonymizer(),
+		stats:         NewRunStats(),
+		temps:         newTempFiles(),
+		learned:       NewLearnedTerms(),
+		coverage:      NewCoverage(),
+		rules:         map[string]*ruleSet{},
+	}
 }
 
 // WithPath sets the path field of the Scheduler object to the provided path parameter and returns a pointer to the modified Scheduler object.
@@ -73,36 +103,182 @@ func (s *Scheduler) WithObfuscation(obfuscation string) *Scheduler {
 	return s
 }
 
+// WithPropagate sets how far values captured by regex patterns are propagated and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - propagate (string): one of PROPAGATE_OFF, PROPAGATE_FILE or PROPAGATE_RUN.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithPropagate(propagate string) *Scheduler {
+	s.propagate = propagate
+	return s
+}
+
+// WithTwoPass enables learning sensitive values from all files before any file is rewritten and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - twoPass (bool): whether to run a learning pass first.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithTwoPass(twoPass bool) *Scheduler {
+	s.twoPass = twoPass
+	return s
+}
+
+// WithOutputDir sets the root directory the anonymized files are written to and returns a pointer to the modified Scheduler object.
+// An empty outputDir writes the anonymized files next to the originals. A relative outputDir is made absolute,
+// so output paths can be compared with the absolute paths of the log files.
+//
+// Parameters:
+// - outputDir (string): the output root directory.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithOutputDir(outputDir string) *Scheduler {
+	if outputDir != "" {
+		if absDir, err := filepath.Abs(outputDir); err == nil {
+			outputDir = absDir
+		}
+	}
+	s.outputDir = outputDir
+	return s
+}
+
+// WithKeepNames sets whether anonymized files in the output directory keep the original file names and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - keepNames (bool): whether to keep the original file names.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithKeepNames(keepNames bool) *Scheduler {
+	s.keepNames = keepNames
+	return s
+}
+
+// WithNoOverwrite sets whether existing output files must be left untouched and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - noOverwrite (bool): whether to refuse overwriting existing output files.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithNoOverwrite(noOverwrite bool) *Scheduler {
+	s.noOverwrite = noOverwrite
+	return s
+}
+
+// WithShutdownTimeout sets how long files in progress may take to finish after an interrupt and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - shutdownTimeout (time.Duration): the grace period for files in progress.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithShutdownTimeout(shutdownTimeout time.Duration) *Scheduler {
+	s.shutdownTimeout = shutdownTimeout
+	return s
+}
+
+// WithVerify enables checking each output file for residual sensitive values and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - verify (bool): whether to verify the output.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithVerify(verify bool) *Scheduler {
+	s.verify = verify
+	return s
+}
+
+// WithUnknownFiles sets the policy for log files whose kind cannot be detected and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - unknownFiles (string): one of UNKNOWN_SKIP, UNKNOWN_FAIL, UNKNOWN_GENERIC or UNKNOWN_QUARANTINE.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithUnknownFiles(unknownFiles string) *Scheduler {
+	s.unknownFiles = unknownFiles
+	return s
+}
+
+// WithSniffLines sets how many lines from the start of a log file are matched against content signatures and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - sniffLines (int): the number of lines, 0 disables content detection.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithSniffLines(sniffLines int) *Scheduler {
+	s.sniffLines = sniffLines
+	return s
+}
+
+// WithAutoVersion enables choosing the axcVersion profile per log file from its header lines and returns a pointer to the modified Scheduler object.
+//
+// Parameters:
+// - autoVersion (bool): whether to detect the axcVersion of each log file.
+//
+// Returns:
+// - *Scheduler: A pointer to the modified Scheduler object.
+func (s *Scheduler) WithAutoVersion(autoVersion bool) *Scheduler {
+	s.autoVersion = autoVersion
+	return s
+}
+
 type logFileInfo struct {
-	kind string
-	path string
+	kind    string
+	path    string
+	relPath string            // path relative to the scheduler path
+	cfg     *AnonymizerConfig // axcVersion profile of the log file
 }
 
 // getOutputFileName returns the output file name for the log file info.
 // It appends ".anonymized" and timestamp to the end of the log file path.
+// With an output directory the relative path of the log file is mirrored
+// under it, optionally keeping the original file name.
+//
+// Parameters:
+//   - outputDir: the output root directory, "" to write next to the log file
+//   - keepNames: whether to keep the original file name in the output directory
 //
 // Returns:
 //   - string: The output file name for the log info.
-func (inf logFileInfo) getOutputFileName() string {
+func (inf logFileInfo) getOutputFileName(outputDir string, keepNames bool) string {
+	outputFileName := inf.path
+	if outputDir != "" {
+		outputFileName = filepath.Join(outputDir, inf.relPath)
+		if keepNames {
+			return outputFileName
+		}
+	}
+
 	now := time.Now()
 	ts := fmt.Sprintf("%d%02d%02d-%02d%02d%02d", now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second())
-	outputFileName := fmt.Sprintf("%s.anonymized.%s", inf.path, ts)
-	return outputFileName
+	return fmt.Sprintf("%s.anonymized.%s", outputFileName, ts)
 }
 
-// getKindByLogPath identifies the kind of log file based on its path and configured naming patterns.
-// It takes the scheduler path, gets naming patterns for the scheduler kind, and checks if the base log file name matches any naming pattern.
+// detectKind identifies the kind of log file based on its name and content.
+// Each kind whose naming patterns match the base log file name scores NAMING_SCORE,
+// and every content signature of a kind matching one of the first sniffLines lines
+// adds CONTENT_SCORE. The kind with the highest score wins; ties are reported as
+// ambiguous and resolved in configuration order.
 //
 // Parameters:
+// - cfg (*AnonymizerConfig): the axcVersion profile of the log file
 // - logFilePath (string): log file path
 //
 // Returns:
 //   - string: The type of log file identified by the provided path.
 //   - error: An error indicating if there were any issues retrieving the type of log file.
-func (s *Scheduler) getKindByLogPath(logFilePath string) (string, error) {
+func (s *Scheduler) detectKind(cfg *AnonymizerConfig, logFilePath string) (string, error) {
 	var err error
 
-	namingPatterns, err := GlobalConfig.GetNamingPatterns(s.kind)
+	namingPatterns, err := cfg.GetNamingPatterns(s.kind)
 	if err != nil {
 		return "", err
 	}
@@ -110,20 +286,137 @@ func (s *Scheduler) getKindByLogPath(logFilePath string) (string, error) {
 	// get base log file name
 	logName := path.Base(logFilePath)
 
+	scores := map[string]int{}
 	for _, namingPattern := range namingPatterns {
 		if namingPattern.Regex.MatchString(logName) {
-			return namingPattern.Kind, nil
+			scores[namingPattern.Kind] = NAMING_SCORE
+		}
+	}
+
+	signatures := cfg.GetContentSignatures(s.kind)
+	if len(signatures) > 0 && s.sniffLines > 0 {
+		lines, err := readHead(logFilePath, s.sniffLines)
+		if err != nil {
+			return "", err
+		}
+		for _, signature := range signatures {
+			for _, line := range lines {
+				if signature.Regex.MatchString(line) {
+					scores[signature.Kind] += CONTENT_SCORE
+					break
+				}
+			}
+		}
+	}
+
+	best, bestScore := []string{}, 0
+	for _, kind := range cfg.kindsInOrder() {
+		switch score := scores[kind]; {
+		case score == 0 || score < bestScore:
+		case score > bestScore:
+			best, bestScore = []string{kind}, score
+		default:
+			best = append(best, kind)
 		}
 	}
 
-	err = fmt.Errorf("not able to detect log type: %s", logName)
-	return "", err
+	if len(best) == 0 {
+		err = fmt.Errorf("not able to detect log type: %s", logName)
+		return "", err
+	}
+
+	// a kind of the profile itself takes precedence over the kinds it inherits
+	if own := cfg.ownKinds(best); len(own) > 0 {
+		best = own
+	}
+
+	if len(best) > 1 {
+		log.Warn().Msgf("ambiguous log type of %s: %s, using %s", logName, strings.Join(best, ", "), best[0])
+		s.stats.AddAmbiguous(logFilePath, best)
+	}
+
+	log.Debug().Msgf("detected [%s] log file %s with score %d", best[0], logName, bestScore)
+	return best[0], nil
+}
+
+// detectVersion chooses the axcVersion profile of a log file by matching the
+// version detection regexes of every profile against its first lines. The first
+// profile with a match wins, unless the regex captures a version, which then
+// selects the best matching profile; without a match GlobalConfig is used.
+//
+// Parameters:
+// - logFilePath (string): log file path
+//
+// Returns:
+//   - *AnonymizerConfig: the axcVersion profile of the log file
+//   - error: An error if the log file cannot be read.
+func (s *Scheduler) detectVersion(logFilePath string) (*AnonymizerConfig, error) {
+	lines, err := readHead(logFilePath, s.sniffLines)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range GlobalConfiguration.AnonymizerConfigs {
+		profile := &GlobalConfiguration.AnonymizerConfigs[i]
+		for _, detection := range profile.GetVersionDetection() {
+			for _, line := range lines {
+				matches := detection.FindStringSubmatch(line)
+				if matches == nil {
+					continue
+				}
+				if len(matches) > 1 && matches[1] != "" {
+					if cfg, err := GlobalConfiguration.GetAnonymizerConfigByAxcVersion(matches[1]); err == nil {
+						return cfg, nil
+					}
+				}
+				return GlobalConfiguration.resolveAnonymizerConfig(profile.AxcVersion, nil)
+			}
+		}
+	}
+
+	log.Warn().Msgf("no axcVersion detected for %s, using %s", path.Base(logFilePath), GlobalConfig.AxcVersion)
+	return GlobalConfig, nil
+}
+
+// sameFile reports whether two paths name the same file, also through links.
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// readHead returns up to n lines from the start of a file.
+func readHead(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	fs := bufio.NewScanner(f)
+	for len(lines) < n && fs.Scan() {
+		lines = append(lines, fs.Text())
+	}
+
+	return lines, fs.Err()
 }
 
 // getLogs walks the scheduler's configured path, collects information
 // about each log file, determines the kind of log based on configured
 // naming patterns, and returns a slice of logFileInfo structs
-// containing the path and kind of each log file.
+// containing the path and kind of each log file. Files without a kind are
+// handled according to the unknown files policy.
 //
 // Returns:
 //   - []logInfo: a slice of logInfo structs containing the path and kind of each log file.
@@ -131,11 +424,30 @@ func (s *Scheduler) getKindByLogPath(logFilePath string) (string, error) {
 func (s *Scheduler) getLogs() ([]logFileInfo, error) {
 	var infos []logFileInfo
 
-	err := filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
+	root, err := s.getRootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	outputDir := ""
+	if s.outputDir != "" {
+		if outputDir, err = filepath.Abs(s.outputDir); err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// never pick up the output of a previous run
+		if info.IsDir() && outputDir != "" {
+			if absPath, err := filepath.Abs(path); err == nil && absPath == outputDir {
+				return filepath.SkipDir
+			}
+		}
+
 		if !info.IsDir() {
 			var kind string
 
@@ -145,58 +457,309 @@ func (s *Scheduler) getLogs() ([]logFileInfo, error) {
 				return nil
 			}
 
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return nil
+			}
+			relPath, _ := filepath.Rel(root, absPath)
+
+			// get axcVersion profile of log file
+			cfg := GlobalConfig
+			if s.autoVersion {
+				if cfg, err = s.detectVersion(path); err != nil {
+					log.Warn().Msgf("%s", err)
+					return nil
+				}
+			}
+
 			// get kind of log file
 			kind = s.kind
 			if kind == "*" {
-				kind, err = s.getKindByLogPath(path)
+				kind, err = s.detectKind(cfg, path)
 				if err != nil {
-					log.Warn().Msgf("%s", err)
-					return nil
+					switch s.unknownFiles {
+					case UNKNOWN_GENERIC:
+						log.Warn().Msgf("%s, applying %s kind", err, GENERIC_KIND)
+						kind = GENERIC_KIND
+					case UNKNOWN_FAIL, UNKNOWN_QUARANTINE:
+						log.Warn().Msgf("%s", err)
+						s.unrecognised = append(s.unrecognised, logFileInfo{path: absPath, relPath: relPath, cfg: cfg})
+						return nil
+					default:
+						log.Warn().Msgf("%s", err)
+						return nil
+					}
 				}
 			}
 
-			if absPath, err := filepath.Abs(path); err == nil {
-				infos = append(infos, logFileInfo{path: absPath, kind: kind})
+			if s.autoVersion {
+				s.stats.AddVersion(absPath, cfg.AxcVersion)
 			}
+			infos = append(infos, logFileInfo{path: absPath, relPath: relPath, kind: kind, cfg: cfg})
 		}
 		return nil
 	})
+	if err != nil {
+		return infos, err
+	}
+
+	if s.unknownFiles == UNKNOWN_FAIL && len(s.unrecognised) > 0 {
+		err = fmt.Errorf("%d unrecognised log files, e.g. %s", len(s.unrecognised), s.unrecognised[0].path)
+		return infos, withExitCode(EXIT_UNRECOGNISED_FILES, err)
+	}
+
+	return infos, nil
+}
+
+// quarantine lists the unrecognised log files in a manifest in the output
+// directory, so they are not shipped without review. The manifest is never
+// written next to the logs, where it could overwrite or be mistaken for one.
+//
+// Returns:
+//   - []FileResult: a quarantined result for each unrecognised log file
+//   - error: any error encountered while writing the manifest
+func (s *Scheduler) quarantine() ([]FileResult, error) {
+	if len(s.unrecognised) == 0 {
+		return nil, nil
+	}
+
+	if s.outputDir == "" {
+		return nil, fmt.Errorf("--unknownFiles %s requires --outputDir", UNKNOWN_QUARANTINE)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# unrecognised log files, not anonymized\n")
+
+	var results []FileResult
+	for _, info := range s.unrecognised {
+		sb.WriteString(info.relPath + "\n")
+		results = append(results, FileResult{Info: info, Status: FILE_QUARANTINED})
+	}
+
+	if err := os.MkdirAll(s.outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	manifest := filepath.Join(s.outputDir, QUARANTINE_MANIFEST)
+	if err := os.WriteFile(manifest, []byte(sb.String()), 0644); err != nil {
+		return nil, err
+	}
+	log.Warn().Msgf("%d unrecognised log files listed in %s", len(results), manifest)
+
+	return results, nil
+}
+
+// getRootDir returns the absolute directory the relative paths of the log files
+// are based on: the scheduler path itself, or its parent if it is a file.
+//
+// Returns:
+//   - string: the absolute root directory
+//   - error: an error if the scheduler path cannot be accessed
+func (s *Scheduler) getRootDir() (string, error) {
+	root, err := filepath.Abs(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	fi, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
 
-	return infos, err
+	if !fi.IsDir() {
+		root = filepath.Dir(root)
+	}
+
+	return root, nil
 }
 
 // Process processes log files using a worker pool.
 //
-// This function creates a worker pool of goroutines to process log files.
-// Each worker goroutine takes a logInfo from the 'pathChan' channel, processes it using the 'processFile' method,
-// and logs any errors that occur during processing.
+// In two-pass mode every file is first scanned by the worker pool to learn the
+// values captured by the regex patterns and path anonymizers, so the second pass
+// redacts them consistently in all files, including earlier occurrences.
+//
+// Once ctx is cancelled no further files are started. Files in progress may
+// finish within the shutdown timeout, after that or on a second interrupt they
+// are aborted and their partial output is removed.
 //
 // Parameters:
+//   - ctx (context.Context): cancelled to stop the run, e.g. on SIGINT.
 //   - infos ([]LogFileInfo): a slice of logFileInfo structs containing the path and kind of each log file.
 //
-// This function does not return any values.
-func (s *Scheduler) Process(infos []logFileInfo) {
+// Returns:
+//   - []FileResult: the outcome of each log file, in the order of infos.
+func (s *Scheduler) Process(ctx context.Context, infos []logFileInfo) []FileResult {
+	if s.twoPass {
+		s.runWorkers(ctx, infos, s.learnLogFile)
+		if ctx.Err() != nil {
+			// without the learned values no file may be rewritten
+			results := make([]FileResult, len(infos))
+			for i, info := range infos {
+				results[i] = FileResult{Info: info, Status: FILE_NOT_STARTED}
+			}
+			return results
+		}
+		log.Debug().Msgf("learned %d sensitive values", len(s.learned.Values()))
+	}
+
+	return s.runWorkers(ctx, infos, s.processFile)
+}
+
+// Cover counts the matches of the regex patterns across the log files without
+// writing any output, see Coverage.
+//
+// Parameters:
+//   - ctx (context.Context): stops dispatching when cancelled.
+//   - infos ([]LogFileInfo): the log files to scan.
+//
+// Returns:
+//   - []FileResult: the outcome of each log file, in the order of infos.
+func (s *Scheduler) Cover(ctx context.Context, infos []logFileInfo) []FileResult {
+	s.coverage.addProfile(GlobalConfig)
+	return s.runWorkers(ctx, infos, s.coverLogFile)
+}
+
+// runWorkers creates a worker pool of goroutines to handle log files.
+// Each worker goroutine takes the index of a logInfo from the 'indexChan' channel, handles it using fn,
+// and logs any errors that occur.
+//
+// Parameters:
+//   - ctx (context.Context): stops dispatching when cancelled.
+//   - infos ([]LogFileInfo): the log files to handle.
+//   - fn (func(context.Context, logFileInfo) error): the function applied to each log file.
+//
+// Returns:
+//   - []FileResult: the outcome of each log file, in the order of infos.
+func (s *Scheduler) runWorkers(ctx context.Context, infos []logFileInfo, fn func(context.Context, logFileInfo) error) []FileResult {
 	var wg sync.WaitGroup
-	pathChan := make(chan logFileInfo, s.workerCount)
+	var mu sync.Mutex
+
+	results := make([]FileResult, len(infos))
+	for i, info := range infos {
+		results[i] = FileResult{Info: info, Status: FILE_NOT_STARTED}
+	}
+
+	// workCtx aborts files in progress once the shutdown timeout has passed
+	workCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+
+	indexChan := make(chan int, s.workerCount)
 
 	for i := 0; i < s.workerCount; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for info := range pathChan {
-				if err := s.processFile(info); err != nil {
-					log.Error().Msgf("%s", err)
+			for index := range indexChan {
+				// files still queued when interrupted are never started
+				if ctx.Err() != nil {
+					continue
 				}
+
+				// counts as aborted unless fn returns
+				mu.Lock()
+				results[index].Status = FILE_ABORTED
+				mu.Unlock()
+
+				result := newFileResult(infos[index], fn(workCtx, infos[index]))
+				switch result.Status {
+				case FILE_FAILED:
+					log.Error().Msgf("%s", result.Err)
+				case FILE_ABORTED:
+					log.Warn().Msgf("%s", result.Err)
+				}
+
+				mu.Lock()
+				results[index] = result
+				mu.Unlock()
 			}
 		}()
 	}
 
-	for _, info := range infos {
-		pathChan <- info
+dispatch:
+	for index := range infos {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexChan <- index:
+		}
+	}
+	close(indexChan)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.awaitShutdown(done, abort)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]FileResult{}, results...)
+}
+
+// awaitShutdown gives the files in progress the shutdown timeout to finish once
+// the run was interrupted, then aborts them. A second interrupt aborts them at once.
+// Aborted workers get ABORT_GRACE_PERIOD to remove their partial output; workers
+// that do not stop by then, or when interrupted a third time, are given up on and
+// their partial output is removed.
+//
+// Parameters:
+//   - done (<-chan struct{}): closed when every worker has returned.
+//   - abort (context.CancelFunc): cancels the context of the files in progress.
+func (s *Scheduler) awaitShutdown(done <-chan struct{}, abort context.CancelFunc) {
+	// the first interrupt is consumed by the cancelled context, listen for the next one
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	log.Warn().Msgf("interrupted, waiting up to %s for files in progress, interrupt again to stop now", s.shutdownTimeout)
+	select {
+	case <-done:
+		return
+	case <-interrupt:
+		log.Warn().Msg("interrupted again, aborting files in progress")
+		abort()
+	case <-time.After(s.shutdownTimeout):
+		abort()
+	}
+
+	select {
+	case <-done:
+	case <-interrupt:
+		log.Warn().Msg("interrupted again, giving up on files in progress")
+		s.temps.removeAll()
+	case <-time.After(ABORT_GRACE_PERIOD):
+		// a worker is stuck, e.g. on a hung read, give up on it
+		log.Warn().Msg("files in progress did not stop, giving up")
+		s.temps.removeAll()
+	}
+}
+
+// learnLogFile is the first pass of two-pass mode. It adds the sensitive values
+// found in a log file to the values learned across the run.
+//
+// Parameters:
+//   - ctx: aborts scanning when cancelled
+//   - info: logFileInfo containing log file path and type
+//
+// Returns:
+//   - error: any error encountered while scanning the file
+func (s *Scheduler) learnLogFile(ctx context.Context, info logFileInfo) error {
+	log.Debug().Msgf("learning [%s] log file: %s", info.kind, info.path)
+
+	rules, err := s.getRuleSet(info.cfg, info.kind)
+	if err != nil {
+		return err
 	}
-	close(pathChan)
 
-	wg.Wait()
+	return s.learnFile(ctx, info.path, rules, s.learned)
 }
 
 // processFile processes an individual log file.
@@ -204,39 +767,85 @@ func (s *Scheduler) Process(infos []logFileInfo) {
 // and writes the anonymized output to a new file.
 //
 // Parameters:
+//   - ctx: aborts processing when cancelled, discarding the partial output
 //   - info: logFileInfo containing log file path and type
 //
 // Returns:
 //   - error: any error encountered while processing the file
-func (s *Scheduler) processFile(info logFileInfo) error {
+func (s *Scheduler) processFile(ctx context.Context, info logFileInfo) error {
 	var err error
 
-	log.Debug().Msgf("processing [%s] log file: %s", info.kind, info.path)
+	log.Debug().Msgf("processing [%s] %s log file: %s", info.kind, info.cfg.AxcVersion, info.path)
 
-	regexes, err := GlobalConfig.GetRegexPatterns(info.kind)
+	rules, err := s.getRuleSet(info.cfg, info.kind)
 	if err != nil {
 		return err
 	}
 
+	var learned *LearnedTerms
+	switch {
+	case s.twoPass:
+		// the first pass has learned the values of all files
+		learned = s.learned
+	case s.propagate == PROPAGATE_FILE:
+		// learn the whole file first so earlier occurrences are redacted too
+		learned = NewLearnedTerms()
+		if err = s.learnFile(ctx, info.path, rules, learned); err != nil {
+			return err
+		}
+	case s.propagate == PROPAGATE_RUN:
+		learned = s.learned
+	}
+
 	inf, err := os.Open(info.path)
 	if err != nil {
 		return err
 	}
 	defer inf.Close()
 
-	anonymizedFileName := info.getOutputFileName()
-	outf, err := os.Create(anonymizedFileName)
+	stat, err := inf.Stat()
+	if err != nil {
+		return err
+	}
+
+	anonymizedFileName := info.getOutputFileName(s.outputDir, s.keepNames)
+	if sameFile(anonymizedFileName, info.path) {
+		return fmt.Errorf("refusing to overwrite original log file %s", info.path)
+	}
+
+	if s.noOverwrite {
+		if _, err := os.Stat(anonymizedFileName); err == nil {
+			return fmt.Errorf("output file %s already exists", anonymizedFileName)
+		}
+	}
+
+	if err = os.MkdirAll(filepath.Dir(anonymizedFileName), 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file with the mode of the log file, renamed into place only on success
+	outf, err := s.temps.create(anonymizedFileName, stat.Mode())
 	if err != nil {
 		return err
 	}
-	defer outf.Close()
+	committed := false
+	defer func() {
+		if !committed {
+			s.temps.discard(outf)
+		}
+	}()
 
 	fs := bufio.NewScanner(inf)
 
 	wf := bufio.NewWriter(outf)
 
+	lines := 0
 	for fs.Scan() {
-		line := s.obfuscate(fs.Text(), regexes)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("aborted %s: %w", info.path, err)
+		}
+		lines++
+		line := s.obfuscate(fs.Text(), rules, learned)
 
 		_, err := fmt.Fprintln(wf, line)
 		if err != nil {
@@ -255,38 +864,321 @@ func (s *Scheduler) processFile(info logFileInfo) error {
 		return err
 	}
 
+	committed = true
+	if err = s.temps.commit(outf, anonymizedFileName, s.noOverwrite); err != nil {
+		return err
+	}
+
+	if s.verify {
+		if err = s.verifyOutput(ctx, info, anonymizedFileName, rules); err != nil {
+			return err
+		}
+	}
+
+	s.stats.AddFile(lines)
 	log.Debug().Msgf("finished processing [%s] log file: %s", info.kind, info.path)
 
 	return nil
 }
 
-// obfuscate takes a log line and a slice of obfuscation patterns, and returns
+// ruleSet bundles the rules applied to every line of one log kind.
+type ruleSet struct {
+	kind      string
+	regexes   []Pattern
+	paths     *PathAnonymizer
+	allowlist *Allowlist
+	denylists []*TermMatcher
+}
+
+// getRuleSet collects the regex patterns, path anonymizer, allowlist and denylists configured for a log kind.
+// Rule sets are cached per axcVersion profile, so term lists are only loaded once per run.
+//
+// Parameters:
+//   - cfg: the axcVersion profile
+//   - kind: the log kind
+//
+// Returns:
+//   - *ruleSet: the rules for the kind
+//   - error: any error encountered while building the rules
+func (s *Scheduler) getRuleSet(cfg *AnonymizerConfig, kind string) (*ruleSet, error) {
+	s.rulesMu.Lock()
+	defer s.rulesMu.Unlock()
+
+	key := cfg.AxcVersion + "/" + kind
+	if rules, ok := s.rules[key]; ok {
+		return rules, nil
+	}
+
+	if kind == GENERIC_KIND {
+		cfg = cfg.withGenericKind()
+	}
+
+	regexes, err := cfg.GetRegexPatterns(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := cfg.GetPathAnonymizer(kind, s.pseudonymizer)
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist, err := cfg.GetAllowlist(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	denylists, err := cfg.GetDenylists(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &ruleSet{kind: kind, regexes: regexes, paths: paths, allowlist: allowlist, denylists: denylists}
+	s.rules[key] = rules
+
+	return rules, nil
+}
+
+// obfuscate takes a log line and the rules of its kind, and returns
 // the log line with sensitive information obfuscated.
 //
-// It iterates through each obfuscation pattern and replaces any matches in
-// the log line with the pattern's obfuscation string.
+// It iterates through each obfuscation pattern and replaces any captured
+// values in the log line with the obfuscation string, keeping values on
+// the allowlist, then pseudonymizes the configured path segments and
+// redacts denylisted and learned terms.
 //
 // Parameters:
 //   - line: the log line to obfuscate
-//   - regexes: slice of obfuscation patterns to apply
+//   - rules: the rules to apply
+//   - learned: collects captured values and redacts them elsewhere, may be nil
 //
 // Returns:
 //   - The obfuscated log line
-func (s *Scheduler) obfuscate(line string, regexes []Pattern) string {
-	for _, re := range regexes {
-		line = re.Regex.ReplaceAllStringFunc(line, func(matched string) string {
-			matches := re.Regex.FindStringSubmatch(matched)
-			log.Debug().Msgf("- [matched] %s", line)
-			for _, match := range matches[1:] {
-				matched = strings.Replace(matched, match, s.obfuscation, 1)
-			}
-			return matched
-		})
+func (s *Scheduler) obfuscate(line string, rules *ruleSet, learned *LearnedTerms) string {
+	for _, re := range rules.regexes {
+		line = s.applyPattern(line, re, rules, learned)
+	}
+
+	if rules.paths != nil {
+		line = rules.paths.Anonymize(line)
+	}
+
+	for _, denylist := range rules.denylists {
+		line, _ = s.applyTerms(line, denylist, CATEGORY_DENYLIST)
 	}
+
+	if matcher := learned.Matcher(); matcher != nil {
+		line, _ = s.applyTerms(line, matcher, CATEGORY_LEARNED)
+	}
+
 	return line
 }
 
-// getAnonymizedLogs collect anonymized log file names.
+// applyPattern replaces the values captured by a single regex pattern in line,
+// keeping values on the allowlist of rules. Each value is replaced at the offsets
+// it was captured at, not wherever it occurs first in the line.
+func (s *Scheduler) applyPattern(line string, re Pattern, rules *ruleSet, learned *LearnedTerms) string {
+	locs := re.Regex.FindAllStringSubmatchIndex(line, -1)
+	if len(locs) == 0 {
+		return line
+	}
+	log.Debug().Msgf("- [matched] %s", line)
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range locs {
+		captures := patternCaptures(rules.allowlist, line, loc)
+		sort.SliceStable(captures, func(i, j int) bool { return captures[i].start < captures[j].start })
+
+		for _, c := range captures {
+			switch c.decision {
+			case CAPTURE_ALLOWLISTED:
+				s.stats.AddAllowlistHit(rules.kind, c.value)
+			case CAPTURE_REPLACED:
+				sb.WriteString(line[last:c.start])
+				sb.WriteString(s.replacement(re, c.group-1, c.value))
+				last = c.end
+				s.stats.AddReplacements(re.category(c.group-1), 1)
+				learned.Add(c.value)
+			}
+		}
+	}
+	sb.WriteString(line[last:])
+
+	return sb.String()
+}
+
+// decisions on the value captured by a group, see patternCaptures
+const (
+	CAPTURE_REPLACED     = "replaced"
+	CAPTURE_ALLOWLISTED  = "allowlisted"
+	CAPTURE_NOT_CAPTURED = "not captured"
+	CAPTURE_NESTED       = "inside a replaced group"
+)
+
+// capture is the value a group captured in one match of a pattern.
+type capture struct {
+	group      int // 1-based group number
+	start, end int // byte offsets in the line, -1 if not captured
+	value      string
+	decision   string
+}
+
+// patternCaptures decides for every group of a match of a pattern whether its value
+// is replaced. Groups that did not take part in the match and groups nested in a
+// replaced group are left alone.
+//
+// Parameters:
+//   - allowlist (*Allowlist): The allowlist of the kind, may be nil.
+//   - line (string): The matched line.
+//   - loc ([]int): The submatch offsets of the match, see regexp.FindStringSubmatchIndex.
+//
+// Returns:
+//   - []capture: The groups in order.
+func patternCaptures(allowlist *Allowlist, line string, loc []int) []capture {
+	var captures []capture
+	var order []int
+	for i := 1; 2*i+1 < len(loc); i++ {
+		captures = append(captures, capture{group: i, start: loc[2*i], end: loc[2*i+1]})
+		order = append(order, i-1)
+	}
+	// decide in the order of the offsets, so an enclosing group comes first
+	sort.SliceStable(order, func(i, j int) bool { return captures[order[i]].start < captures[order[j]].start })
+
+	replacedEnd := 0
+	for _, i := range order {
+		c := &captures[i]
+		switch {
+		case c.start < 0:
+			c.decision = CAPTURE_NOT_CAPTURED
+			continue
+		case c.start < replacedEnd:
+			c.value = line[c.start:c.end]
+			c.decision = CAPTURE_NESTED
+			continue
+		}
+
+		c.value = line[c.start:c.end]
+		if allowlist.Allowed(c.value) {
+			c.decision = CAPTURE_ALLOWLISTED
+			continue
+		}
+		c.decision = CAPTURE_REPLACED
+		replacedEnd = c.end
+	}
+
+	return captures
+}
+
+// applyTerms redacts the terms of matcher in line and returns the number of
+// redacted occurrences.
+func (s *Scheduler) applyTerms(line string, matcher *TermMatcher, category string) (string, int) {
+	line, count := matcher.Replace(line, func(string) string { return s.obfuscation })
+	s.stats.AddReplacements(category, count)
+	return line, count
+}
+
+// replacement returns the text that replaces the value captured by group i of
+// pattern, depending on the strategy of the pattern.
+func (s *Scheduler) replacement(pattern Pattern, i int, value string) string {
+	if pattern.Strategy != STRATEGY_PSEUDONYMIZE {
+		return s.obfuscation
+	}
+	return s.pseudonymizer.Pseudonym(pattern.category(i), value)
+}
+
+// learnFile collects the values captured by the regex patterns and the path
+// segments selected by the path anonymizer of rules in a log file without
+// writing any output.
+//
+// Parameters:
+//   - ctx: aborts scanning when cancelled
+//   - path: the log file to scan
+//   - rules: the rules of the log kind
+//   - learned: receives the captured values
+//
+// Returns:
+//   - error: any error encountered while reading the file
+func (s *Scheduler) learnFile(ctx context.Context, path string, rules *ruleSet, learned *LearnedTerms) error {
+	inf, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer inf.Close()
+
+	fs := bufio.NewScanner(inf)
+	for fs.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("aborted %s: %w", path, err)
+		}
+		line := fs.Text()
+		for _, re := range rules.regexes {
+			for _, matches := range re.Regex.FindAllStringSubmatch(line, -1) {
+				for _, match := range matches[1:] {
+					if !rules.allowlist.Allowed(match) {
+						learned.Add(match)
+					}
+				}
+			}
+		}
+		if rules.paths != nil {
+			for _, value := range rules.paths.Values(line) {
+				learned.Add(value)
+			}
+		}
+	}
+
+	return fs.Err()
+}
+
+// verifyOutput checks an anonymized file for residual sensitive values: denylisted
+// terms and any value the rules capture in the original log file.
+//
+// Parameters:
+//   - ctx: aborts verification when cancelled
+//   - info: the original log file
+//   - output: the anonymized file
+//   - rules: the rules of the log kind
+//
+// Returns:
+//   - error: any error encountered while reading the files
+func (s *Scheduler) verifyOutput(ctx context.Context, info logFileInfo, output string, rules *ruleSet) error {
+	captured := NewLearnedTerms()
+	if err := s.learnFile(ctx, info.path, rules, captured); err != nil {
+		return err
+	}
+
+	matchers := append([]*TermMatcher{captured.Matcher()}, rules.denylists...)
+
+	f, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	residual := 0
+	fs := bufio.NewScanner(f)
+	for lineNo := 1; fs.Scan(); lineNo++ {
+		for _, matcher := range matchers {
+			if matches := matcher.FindAll(fs.Text()); len(matches) > 0 {
+				log.Warn().Msgf("residual sensitive value in %s:%d", output, lineNo)
+				residual++
+				break
+			}
+		}
+	}
+	if err := fs.Err(); err != nil {
+		return err
+	}
+
+	if residual > 0 {
+		s.stats.AddResidual(output, residual)
+	}
+
+	return nil
+}
+
+// getAnonymizedLogs collect anonymized log file names and stale temporary output files.
 //
 // Returns:
 //   - []string: Slice of paths for the anonymized log files
@@ -302,7 +1194,7 @@ func (s *Scheduler) getAnonymizedLogs() ([]string, error) {
 
 		if !info.IsDir() {
 			baseName := filepath.Base(path)
-			if strings.Contains(baseName, ".anonymized.") {
+			if strings.Contains(baseName, ".anonymized.") || isTempFile(baseName) {
 				absPath, _ := filepath.Abs(path)
 				paths = append(paths, absPath)
 			}
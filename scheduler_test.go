@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestProcessFileRefusesToOverwriteInput(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	cfg, err := LoadConfig(DEFAULT_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := cfg.GetAnonymizerConfigByAxcVersion(DEFAULT_AXC_VERSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	original := "SearchError User : jdoe Duration 12ms\n"
+	logPath := filepath.Join(dir, "MindServer.log")
+	if err := os.WriteFile(logPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// run --path ./MindServer.log --outputDir . --keepNames
+	s := NewScheduler().
+		WithObfuscation(DEFAULT_OBFUSCATION).
+		WithOutputDir(".").
+		WithKeepNames(true)
+
+	info := logFileInfo{kind: "engine", path: logPath, relPath: "MindServer.log", cfg: profile}
+	if err := s.processFile(context.Background(), info); err == nil {
+		t.Fatal("expected processFile to refuse overwriting the log file")
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != original {
+		t.Errorf("log file was overwritten: %q", content)
+	}
+}
+
+func TestQuarantineWritesManifestToOutputDir(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	cfg, err := LoadConfig(DEFAULT_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := GlobalConfig
+	defer func() { GlobalConfig = saved }()
+	if GlobalConfig, err = cfg.GetAnonymizerConfigByAxcVersion(DEFAULT_AXC_VERSION); err != nil {
+		t.Fatal(err)
+	}
+
+	// a customer file named like the manifest is an unrecognised log like any other
+	logDir, outputDir := t.TempDir(), t.TempDir()
+	customer := "jdoe was here\n"
+	if err := os.WriteFile(filepath.Join(logDir, QUARANTINE_MANIFEST), []byte(customer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler().
+		WithPath(logDir).
+		WithKind("*").
+		WithOutputDir(outputDir).
+		WithUnknownFiles(UNKNOWN_QUARANTINE)
+
+	infos, err := s.getLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quarantined, err := s.quarantine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 || len(quarantined) != 1 {
+		t.Fatalf("expected 1 quarantined file, got %d logs and %d quarantined", len(infos), len(quarantined))
+	}
+
+	content, err := os.ReadFile(filepath.Join(logDir, QUARANTINE_MANIFEST))
+	if err != nil || string(content) != customer {
+		t.Errorf("input file was overwritten: %q, %v", content, err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(outputDir, QUARANTINE_MANIFEST))
+	if err != nil || !strings.Contains(string(manifest), QUARANTINE_MANIFEST) {
+		t.Errorf("manifest in the output directory does not list the file: %q, %v", manifest, err)
+	}
+
+	if code := exitCode(aggregateResults(quarantined, 0)); code != EXIT_UNRECOGNISED_FILES {
+		t.Errorf("exit code %d, want %d", code, EXIT_UNRECOGNISED_FILES)
+	}
+}
+
+func TestProcessFileKeepsFileMode(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	cfg, err := LoadConfig(DEFAULT_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := cfg.GetAnonymizerConfigByAxcVersion(DEFAULT_AXC_VERSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logDir, outputDir := t.TempDir(), t.TempDir()
+	logPath := filepath.Join(logDir, "MindServer.log")
+	if err := os.WriteFile(logPath, []byte("SearchError User : jdoe Duration 12ms\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(logPath, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler().
+		WithObfuscation(DEFAULT_OBFUSCATION).
+		WithOutputDir(outputDir)
+
+	info := logFileInfo{kind: "engine", path: logPath, relPath: "MindServer.log", cfg: profile}
+	if err := s.processFile(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(info.getOutputFileName(outputDir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := stat.Mode().Perm(); mode != 0640 {
+		t.Errorf("output file mode %o, want 640", mode)
+	}
+}
+
+func TestRunWorkersStopsOnSecondInterrupt(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	// keep the interrupts sent below from terminating the test binary
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	s := NewScheduler().
+		WithWorkerCount(1).
+		WithShutdownTimeout(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	hang := make(chan struct{})
+	defer close(hang)
+
+	// a worker that ignores its context, e.g. stuck on a hung read
+	fn := func(context.Context, logFileInfo) error {
+		close(started)
+		<-hang
+		return nil
+	}
+
+	results := make(chan []FileResult, 1)
+	go func() {
+		results <- s.runWorkers(ctx, []logFileInfo{{path: "MindServer.log"}}, fn)
+	}()
+
+	<-started
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r := <-results:
+			if len(r) != 1 || r[0].Status != FILE_ABORTED {
+				t.Errorf("expected the file in progress to be aborted, got %+v", r)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+			// the second interrupt, repeated until the shutdown listens for it
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(os.Interrupt)
+			}
+		case <-timeout:
+			t.Fatal("runWorkers did not return on a second interrupt")
+		}
+	}
+}
+
+func TestRunWorkersWaitsForAbortedFilesOnSecondInterrupt(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	s := NewScheduler().
+		WithWorkerCount(1).
+		WithShutdownTimeout(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started, aborted := make(chan struct{}), make(chan struct{})
+	cleanedUp := false
+
+	// a worker that takes a while to remove its partial output once aborted
+	fn := func(ctx context.Context, _ logFileInfo) error {
+		close(started)
+		<-ctx.Done()
+		close(aborted)
+		time.Sleep(100 * time.Millisecond)
+		cleanedUp = true
+		return ctx.Err()
+	}
+
+	results := make(chan []FileResult, 1)
+	go func() {
+		results <- s.runWorkers(ctx, []logFileInfo{{path: "MindServer.log"}}, fn)
+	}()
+
+	<-started
+	cancel()
+
+	// the second interrupt, repeated until the shutdown listens for it
+	timeout := time.After(5 * time.Second)
+interrupting:
+	for {
+		select {
+		case <-aborted:
+			break interrupting
+		case <-time.After(20 * time.Millisecond):
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(os.Interrupt)
+			}
+		case <-timeout:
+			t.Fatal("second interrupt did not abort the file in progress")
+		}
+	}
+
+	select {
+	case r := <-results:
+		if !cleanedUp {
+			t.Error("runWorkers returned before the aborted worker stopped")
+		}
+		if len(r) != 1 || r[0].Status != FILE_ABORTED {
+			t.Errorf("expected the file in progress to be aborted, got %+v", r)
+		}
+	case <-time.After(ABORT_GRACE_PERIOD + time.Second):
+		t.Fatal("runWorkers did not return after the aborted worker stopped")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const SCHEMA_DRAFT = "https://json-schema.org/draft/2020-12/schema"
+
+// yamlFields returns the configuration keys of a struct type and their fields.
+func yamlFields(t reflect.Type) ([]string, map[string]reflect.StructField) {
+	var names []string
+	fields := map[string]reflect.StructField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		names = append(names, name)
+		fields[name] = field
+	}
+
+	return names, fields
+}
+
+// checkKnownFields rejects keys that do not belong to the configuration, such as
+// the typo regexPattern, instead of silently ignoring them.
+//
+// Inputs:
+//   - node (*yaml.Node): The parsed configuration.
+//   - t (reflect.Type): The type the node is decoded into.
+//   - path (string): The configuration file, used for error messages.
+//
+// Outputs:
+//   - error: An error with the file and line of the first unknown key.
+func checkKnownFields(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := checkKnownFields(child, t, path); err != nil {
+				return err
+			}
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		names, fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fields[key.Value]
+			if !ok {
+				return fmt.Errorf("%s:%d: unknown key %q, expected one of: %s", path, key.Line, key.Value, strings.Join(names, ", "))
+			}
+			if err := checkKnownFields(node.Content[i+1], field.Type, path); err != nil {
+				return err
+			}
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := checkKnownFields(node.Content[i], t.Elem(), path); err != nil {
+				return err
+			}
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, child := range node.Content {
+			if err := checkKnownFields(child, t.Elem(), path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// JSONSchema builds a JSON Schema of AnonymizerConfiguration, so editors can
+// validate configuration files.
+//
+// Outputs:
+//   - map[string]any: The schema, ready to be marshalled to JSON.
+func JSONSchema() map[string]any {
+	defs := map[string]any{}
+	schema := jsonSchemaOf(reflect.TypeOf(AnonymizerConfiguration{}), defs)
+
+	schema["$schema"] = SCHEMA_DRAFT
+	schema["title"] = "log-anonymizer configuration"
+	schema["$defs"] = defs
+	return schema
+}
+
+// jsonSchemaOf returns the schema of t. Structs other than the root are added
+// to defs and referenced by name.
+func jsonSchemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+		if t != reflect.TypeOf(AnonymizerConfiguration{}) {
+			if _, ok := defs[t.Name()]; !ok {
+				defs[t.Name()] = map[string]any{} // placeholder for recursive types
+				defs[t.Name()] = structSchema(t, defs)
+			}
+			return map[string]any{"$ref": "#/$defs/" + t.Name()}
+		}
+		return structSchema(t, defs)
+	}
+
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	names, fields := yamlFields(t)
+	for _, name := range names {
+		field := fields[name]
+		properties[name] = jsonSchemaOf(field.Type, defs)
+		if !strings.Contains(field.Tag.Get("yaml"), "omitempty") && field.Type.Kind() == reflect.String {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RunStats collects counters across all workers of a run.
+type RunStats struct {
+	mu            sync.Mutex
+	files         int
+	lines         int
+	replacements  map[string]int            // entity category -> replaced values
+	allowlistHits map[string]map[string]int // kind -> value -> hits
+	residual      map[string]int            // output file -> lines with residual sensitive values
+	ambiguous     map[string][]string       // log file -> equally scored kinds
+	versions      map[string]string         // log file -> axcVersion profile
+}
+
+func NewRunStats() *RunStats {
+	return &RunStats{
+		replacements:  map[string]int{},
+		allowlistHits: map[string]map[string]int{},
+		residual:      map[string]int{},
+		ambiguous:     map[string][]string{},
+		versions:      map[string]string{},
+	}
+}
+
+// AddFile records a processed file and its line count.
+func (st *RunStats) AddFile(lines int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.files++
+	st.lines += lines
+}
+
+// AddReplacements records n replaced values.
+//
+// Parameters:
+//   - category (string): The entity category of the values, e.g. email.
+//   - n (int): The number of replaced values.
+func (st *RunStats) AddReplacements(category string, n int) {
+	if n == 0 {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.replacements[category] += n
+}
+
+// AddAllowlistHit records a value kept because it is allowlisted.
+//
+// Parameters:
+//   - kind (string): The log kind the value was found in.
+//   - value (string): The allowlisted value.
+func (st *RunStats) AddAllowlistHit(kind, value string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.allowlistHits[kind] == nil {
+		st.allowlistHits[kind] = map[string]int{}
+	}
+	st.allowlistHits[kind][value]++
+}
+
+// AddResidual records lines of an output file that still contain sensitive values.
+//
+// Parameters:
+//   - path (string): The output file.
+//   - lines (int): The number of lines with residual sensitive values.
+func (st *RunStats) AddResidual(path string, lines int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.residual[path] += lines
+}
+
+// ResidualFiles returns the number of output files with residual sensitive values.
+func (st *RunStats) ResidualFiles() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.residual)
+}
+
+// AddAmbiguous records a log file whose kind could not be told apart.
+//
+// Parameters:
+//   - path (string): The log file.
+//   - kinds ([]string): The kinds with the same score.
+func (st *RunStats) AddAmbiguous(path string, kinds []string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ambiguous[path] = kinds
+}
+
+// Ambiguous returns the equally scored kinds of a log file, nil if its kind was clear.
+func (st *RunStats) Ambiguous(path string) []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.ambiguous[path]
+}
+
+// AddVersion records the axcVersion profile used for a log file.
+//
+// Parameters:
+//   - path (string): The log file.
+//   - version (string): The axcVersion of the profile.
+func (st *RunStats) AddVersion(path, version string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.versions[path] = version
+}
+
+// Print writes a summary of the run to w.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the summary.
+func (st *RunStats) Print(w io.Writer) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	fmt.Fprintf(w, "%-16s%d\n", "files", st.files)
+	fmt.Fprintf(w, "%-16s%d\n", "lines", st.lines)
+	total := 0
+	for _, n := range st.replacements {
+		total += n
+	}
+	fmt.Fprintf(w, "%-16s%d\n", "replacements", total)
+	for _, category := range sortedKeys(st.replacements) {
+		fmt.Fprintf(w, "    %-16s%d\n", category, st.replacements[category])
+	}
+
+	if len(st.allowlistHits) > 0 {
+		fmt.Fprintln(w, "allowlist hits:")
+		for _, kind := range sortedKeys(st.allowlistHits) {
+			for _, value := range sortedKeys(st.allowlistHits[kind]) {
+				fmt.Fprintf(w, "    %-16s%-32s%d\n", kind, value, st.allowlistHits[kind][value])
+			}
+		}
+	}
+
+	if len(st.versions) > 0 {
+		fmt.Fprintln(w, "axcVersions:")
+		for _, path := range sortedKeys(st.versions) {
+			fmt.Fprintf(w, "    %-16s%s\n", st.versions[path], path)
+		}
+	}
+
+	if len(st.ambiguous) > 0 {
+		fmt.Fprintln(w, "ambiguous log types:")
+		for _, path := range sortedKeys(st.ambiguous) {
+			fmt.Fprintf(w, "    %s: %s\n", path, strings.Join(st.ambiguous[path], ", "))
+		}
+	}
+
+	if len(st.residual) > 0 {
+		fmt.Fprintln(w, "residual sensitive values:")
+		for _, path := range sortedKeys(st.residual) {
+			fmt.Fprintf(w, "    %s: %d lines\n", path, st.residual[path])
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// References in configuration values
+//
+// Values may refer to environment variables with ${NAME}, anywhere in the value,
+// and to files with a value of the form file:<path>, relative to the configuration
+// file. Both are resolved when the configuration is loaded; a file reference is
+// replaced by the content of the file without surrounding whitespace and $${ is
+// a literal ${. The resolved values are treated as secrets and redacted from
+// debug output and showConfig.
+//
+// Regexes are taken as they are: the values of the fields in regexFields, such
+// as regexPatterns, are never substituted, so ${ and file: keep their regex meaning.
+
+const (
+	FILE_REFERENCE_PREFIX = "file:"
+	REDACTED              = "[REDACTED]"
+)
+
+var envReferenceRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// regexFields are the configuration keys holding regexes or pattern names.
+var regexFields = map[string]bool{
+	"namingPatterns":       true,
+	"regexPatterns":        true,
+	"contentSignatures":    true,
+	"versionDetection":     true,
+	"caseFolderPatterns":   true,
+	"patterns":             true, // pattern library, pattern names of a kind and allowlist regexes
+	"regex":                true,
+	"removeNamingPatterns": true,
+	"removeRegexPatterns":  true,
+}
+
+// substituteReferences resolves the environment variable and file references of
+// every scalar value below node. Mapping keys and the values of regexFields are
+// left untouched.
+//
+// Inputs:
+//   - node (*yaml.Node): The parsed configuration file.
+//   - path (string): The configuration file, used for error messages and relative file references.
+//
+// Outputs:
+//   - []string: The resolved values, to be redacted from output.
+//   - error: An error naming the file and line of a missing variable or unreadable file.
+func substituteReferences(node *yaml.Node, path string) ([]string, error) {
+	var secrets []string
+
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			value, resolved, err := resolveReferences(n.Value, filepath.Dir(path))
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", path, n.Line, err)
+			}
+			n.Value = value
+			secrets = append(secrets, resolved...)
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				if regexFields[n.Content[i-1].Value] {
+					continue
+				}
+				if err := walk(n.Content[i]); err != nil {
+					return err
+				}
+			}
+		default:
+			for _, child := range n.Content {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return secrets, walk(node)
+}
+
+// resolveReferences resolves the references of a single value.
+func resolveReferences(value, dir string) (string, []string, error) {
+	if strings.HasPrefix(value, FILE_REFERENCE_PREFIX) {
+		file := strings.TrimPrefix(value, FILE_REFERENCE_PREFIX)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return value, nil, fmt.Errorf("file reference: %w", err)
+		}
+		resolved := strings.TrimSpace(string(content))
+		return resolved, []string{resolved}, nil
+	}
+
+	var secrets []string
+	var missing []string
+	value = envReferenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+		name := envReferenceRegex.FindStringSubmatch(reference)[1]
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return reference
+		}
+		secrets = append(secrets, resolved)
+		return resolved
+	})
+
+	if len(missing) > 0 {
+		return value, nil, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return value, secrets, nil
+}
+
+// Redact replaces the resolved references of the configuration in text.
+//
+// Inputs:
+//   - text (string): Text that may contain resolved values, e.g. a %+v dump of the configuration.
+//
+// Outputs:
+//   - string: The text with every resolved value replaced by REDACTED.
+func (cfg *AnonymizerConfiguration) Redact(text string) string {
+	for _, secret := range cfg.secrets {
+		if strings.TrimSpace(secret) == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, secret, REDACTED)
+	}
+	return text
+}
+
+// redactNode applies Redact to every scalar below node.
+func (cfg *AnonymizerConfiguration) redactNode(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		node.Value = cfg.Redact(node.Value)
+	}
+	for _, child := range node.Content {
+		cfg.redactNode(child)
+	}
+}
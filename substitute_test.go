@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubstituteReferencesSkipsRegexes(t *testing.T) {
+	t.Setenv("CUSTOMER_NAME", "Acme")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "matter.txt"), []byte("Project X\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+patterns:
+  price:
+    regex: '.*price \$\{(\d+)\}.*'
+anonymizer:
+  - axcVersion: default
+    versionDetection: ['^version \$\{(.*)\}']
+    allowlist:
+      values: ['${CUSTOMER_NAME}']
+      patterns: ['file:.*']
+    denylist:
+      terms: ['${CUSTOMER_NAME} $${literal}', 'file:matter.txt']
+    logs:
+      - kind: engine
+        namingPatterns: ['${NOT_SET}.*[.]log']
+        regexPatterns: ['.*user \$\{(.*?)\}.*']
+        contentSignatures: ['file:']
+        paths:
+          segments: [user]
+          caseFolderPatterns: ['^${NOT_SET}']
+`
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := cfg.AnonymizerConfigs[0]
+	logCfg := profile.LogConfigs[0]
+
+	tests := []struct {
+		field string
+		got   string
+		want  string
+	}{
+		{"allowlist values", profile.Allowlist.Values[0], "Acme"},
+		{"denylist terms", profile.Denylist.Terms[0], "Acme ${literal}"},
+		{"denylist file reference", profile.Denylist.Terms[1], "Project X"},
+		{"regex", cfg.Patterns["price"].Regex, `.*price \$\{(\d+)\}.*`},
+		{"versionDetection", profile.VersionDetection[0], `^version \$\{(.*)\}`},
+		{"allowlist patterns", profile.Allowlist.Patterns[0], "file:.*"},
+		{"namingPatterns", logCfg.NamingPatterns[0], "${NOT_SET}.*[.]log"},
+		{"regexPatterns", logCfg.RegexPatterns[0], `.*user \$\{(.*?)\}.*`},
+		{"contentSignatures", logCfg.ContentSignatures[0], "file:"},
+		{"caseFolderPatterns", logCfg.Paths.CaseFolderPatterns[0], "^${NOT_SET}"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+}
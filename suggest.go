@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MARK_START      = "{{"
+	MARK_END        = "}}"
+	CONTEXT_WORDS   = 2 // words before the first marked value kept in a suggested regex
+	SUGGESTION_KIND = "newkind"
+)
+
+var (
+	contextDigitsRegex = regexp.MustCompile(`\d+`)
+	categoryRegex      = regexp.MustCompile(`^[A-Za-z]\w*$`)
+)
+
+// markedValue is a sensitive value marked in a sample line as {{value}} or {{category:value}}.
+type markedValue struct {
+	category string
+	group    string // name of the capture group, the category numbered if it is used more than once
+	value    string
+}
+
+// parseMarkedLine splits a sample line into the literal text around the marked
+// values and the values themselves.
+//
+// Parameters:
+//   - line (string): The sample line, e.g. Principals for {{user:jdoe}}<123>.
+//
+// Returns:
+//   - []string: The literal text, one more entry than marked values.
+//   - []markedValue: The marked values in order.
+//   - error: An error if a mark is not closed or the line has no marks.
+func parseMarkedLine(line string) ([]string, []markedValue, error) {
+	var literals []string
+	var values []markedValue
+
+	for {
+		start := strings.Index(line, MARK_START)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(line[start:], MARK_END)
+		if end < 0 {
+			return nil, nil, fmt.Errorf("unclosed %s in sample line", MARK_START)
+		}
+
+		marked := markedValue{value: line[start+len(MARK_START) : start+end]}
+		// C:\Users\jdoe and http://host are values, not categories
+		if category, value, ok := strings.Cut(marked.value, ":"); ok && categoryRegex.MatchString(category) && !strings.HasPrefix(value, "/") && !strings.HasPrefix(value, `\`) {
+			marked = markedValue{category: category, value: value}
+		}
+
+		literals = append(literals, line[:start])
+		values = append(values, marked)
+		line = line[start+end+len(MARK_END):]
+	}
+	literals = append(literals, line)
+
+	if len(values) == 0 {
+		return nil, nil, fmt.Errorf("mark the sensitive values of the sample line like %sjdoe%s", MARK_START, MARK_END)
+	}
+
+	// capture group names must be unique: user, user2, ...
+	used := map[string]int{}
+	for i := range values {
+		if values[i].category == "" {
+			continue
+		}
+		used[values[i].category]++
+		values[i].group = values[i].category
+		if n := used[values[i].category]; n > 1 {
+			values[i].group = fmt.Sprintf("%s%d", values[i].category, n)
+		}
+	}
+
+	return literals, values, nil
+}
+
+// generalize escapes literal text and lets numbers vary.
+func generalize(text string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range contextDigitsRegex.FindAllStringIndex(text, -1) {
+		sb.WriteString(regexp.QuoteMeta(text[last:loc[0]]))
+		sb.WriteString(`\d+`)
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(text[last:]))
+	return sb.String()
+}
+
+// lastWords returns the end of text starting at its n-th last word.
+func lastWords(text string, n int) string {
+	words := 0
+	for i := len(text) - 1; i >= 0; i-- {
+		if text[i] != ' ' && text[i] != '\t' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			words++
+			if words == n {
+				return text[i:]
+			}
+		}
+	}
+	return text
+}
+
+// SuggestRegex generalizes a sample line with marked values into a regex. The
+// text between marked values is kept, the text before the first one is cut down
+// to CONTEXT_WORDS words and the text after the last one to its first word;
+// numbers may vary and each marked value becomes a capture group, named after
+// its category if given. Repeated categories get numbered group names, which
+// suggestionCategories maps back to the category.
+//
+// Parameters:
+//   - line (string): The sample line with marked values.
+//
+// Returns:
+//   - string: The suggested regex.
+//   - []markedValue: The marked values.
+//   - error: An error if the line has no or unclosed marks, or the regex does not reproduce them.
+func SuggestRegex(line string) (string, []markedValue, error) {
+	literals, values, err := parseMarkedLine(line)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	// a value at the start of the line is not preceded by .*, which would swallow it
+	if prefix := lastWords(literals[0], CONTEXT_WORDS); prefix != "" {
+		sb.WriteString(".*")
+		sb.WriteString(generalize(prefix))
+	}
+
+	suffix := literals[len(literals)-1]
+	if end := strings.IndexAny(strings.TrimLeft(suffix, " \t"), " \t"); end >= 0 {
+		suffix = suffix[:len(suffix)-len(strings.TrimLeft(suffix, " \t"))+end]
+	}
+
+	for i, marked := range values {
+		group := "(.*?)"
+		if i == len(values)-1 && suffix == "" {
+			group = `(\S+)`
+		}
+		if marked.group != "" {
+			group = "(?P<" + marked.group + ">" + group[1:]
+		}
+		sb.WriteString(group)
+
+		if i < len(values)-1 {
+			sb.WriteString(generalize(literals[i+1]))
+		}
+	}
+	sb.WriteString(generalize(suffix))
+	sb.WriteString(".*$")
+
+	regex := sb.String()
+	rex, err := regexp.Compile(regex)
+	if err != nil {
+		return "", nil, fmt.Errorf("suggested regex %s is invalid: %w", regex, err)
+	}
+
+	unmarked := strings.Join(literals, "\x00")
+	for _, marked := range values {
+		unmarked = strings.Replace(unmarked, "\x00", marked.value, 1)
+	}
+	captures := rex.FindStringSubmatch(unmarked)
+	if captures == nil {
+		return regex, values, fmt.Errorf("suggested regex %s does not match the sample line", regex)
+	}
+	for i, marked := range values {
+		if captures[i+1] != marked.value {
+			return regex, values, fmt.Errorf("suggested regex %s captures %q instead of %q", regex, captures[i+1], marked.value)
+		}
+	}
+
+	return regex, values, nil
+}
+
+// TestSuggestion matches a regex against every line of the files below corpus and
+// prints the number of matching lines and the captures of up to top of them.
+//
+// Parameters:
+//   - w (io.Writer): The destination of the report.
+//   - regex (string): The regex to test.
+//   - corpus (string): A log file or a directory of log files.
+//   - top (int): The number of matching lines to show.
+//
+// Returns:
+//   - error: Any error encountered while reading the corpus.
+func TestSuggestion(w io.Writer, regex, corpus string, top int) error {
+	rex := regexp.MustCompile(regex)
+	matched, lines, files := 0, 0, 0
+
+	err := filepath.Walk(corpus, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		files++
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		fs := bufio.NewScanner(f)
+		fs.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for n := 1; fs.Scan(); n++ {
+			lines++
+			captures := rex.FindStringSubmatch(fs.Text())
+			if captures == nil {
+				continue
+			}
+			matched++
+			if matched <= top {
+				fmt.Fprintf(w, "    %s:%d %q\n", path, n, captures[1:])
+			}
+		}
+		return fs.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d of %d lines in %d files matched\n", matched, lines, files)
+	return nil
+}
+
+// suggestionCategories returns the categories mapping of the numbered capture
+// group names of values, e.g. user2 -> user.
+func suggestionCategories(values []markedValue) map[string]string {
+	categories := map[string]string{}
+	for _, marked := range values {
+		if marked.group != marked.category {
+			categories[marked.group] = marked.category
+		}
+	}
+	return categories
+}
+
+// suggestionSnippet returns the configuration snippet adding regex to kind,
+// with the categories mapping of numbered capture group names if any.
+func suggestionSnippet(kind, regex string, categories map[string]string) (string, error) {
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+
+	snippet := map[string]any{
+		"logs": []map[string]any{{
+			"kind":          kind,
+			"regexPatterns": []string{regex},
+		}},
+	}
+	if len(categories) > 0 {
+		snippet["categories"] = categories
+	}
+
+	err := enc.Encode(snippet)
+	if err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseMarkedLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		literals []string
+		values   []markedValue
+		wantErr  bool
+	}{
+		{
+			line:     "Principals for {{jdoe}}<123>",
+			literals: []string{"Principals for ", "<123>"},
+			values:   []markedValue{{value: "jdoe"}},
+		},
+		{
+			line:     "{{user:jdoe}} logged in",
+			literals: []string{"", " logged in"},
+			values:   []markedValue{{category: "user", group: "user", value: "jdoe"}},
+		},
+		{
+			line:     "login {{user:jdoe}} from {{user:10.0.0.1}}",
+			literals: []string{"login ", " from ", ""},
+			values:   []markedValue{{category: "user", group: "user", value: "jdoe"}, {category: "user", group: "user2", value: "10.0.0.1"}},
+		},
+		{
+			// not a category, the colon is part of the value
+			line:     "url {{http://host}}",
+			literals: []string{"url ", ""},
+			values:   []markedValue{{value: "http://host"}},
+		},
+		{
+			line:     `path {{C:\Users\jdoe}}`,
+			literals: []string{"path ", ""},
+			values:   []markedValue{{value: `C:\Users\jdoe`}},
+		},
+		{line: "no marks", wantErr: true},
+		{line: "open {{jdoe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		literals, values, err := parseMarkedLine(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMarkedLine(%q): expected an error", tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMarkedLine(%q): %s", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(literals, tt.literals) || !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("parseMarkedLine(%q) = %q, %+v, want %q, %+v", tt.line, literals, values, tt.literals, tt.values)
+		}
+	}
+}
+
+func TestGeneralize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Principals for ", "Principals for "},
+		{"<123>", `<\d+>`},
+		{"[main] a.b(c)", `\[main\] a\.b\(c\)`},
+		{"v22.0 ", `v\d+\.\d+ `},
+	}
+
+	for _, tt := range tests {
+		if got := generalize(tt.text); got != tt.want {
+			t.Errorf("generalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLastWords(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"", 2, ""},
+		{"for ", 2, "for "},
+		{"INFO [main] Principals for ", 2, "Principals for "},
+		{"a  b\tc ", 2, "b\tc "},
+		{"a b", 5, "a b"},
+	}
+
+	for _, tt := range tests {
+		if got := lastWords(tt.text, tt.n); got != tt.want {
+			t.Errorf("lastWords(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestRegex(t *testing.T) {
+	tests := []struct {
+		line     string
+		regex    string
+		unmarked string
+	}{
+		{
+			line:     "2024-01-01 INFO [main] Principals for {{user:jdoe}}<123> ok",
+			regex:    `^.*Principals for (?P<user>.*?)<\d+>.*$`,
+			unmarked: "2024-01-01 INFO [main] Principals for jdoe<123> ok",
+		},
+		{
+			line:     "{{jdoe}}",
+			regex:    `^(\S+).*$`,
+			unmarked: "jdoe",
+		},
+		{
+			line:     "{{user:jdoe}} logged in",
+			regex:    `^(?P<user>.*?) logged.*$`,
+			unmarked: "jdoe logged in",
+		},
+		{
+			line:     "login {{user:jdoe}} from {{user:10.0.0.1}}",
+			regex:    `^.*login (?P<user>.*?) from (?P<user2>\S+).*$`,
+			unmarked: "login jdoe from 10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		regex, values, err := SuggestRegex(tt.line)
+		if err != nil {
+			t.Errorf("SuggestRegex(%q): %s", tt.line, err)
+			continue
+		}
+		if regex != tt.regex {
+			t.Errorf("SuggestRegex(%q) = %s, want %s", tt.line, regex, tt.regex)
+		}
+
+		rex := regexp.MustCompile(regex)
+		captures := rex.FindStringSubmatch(tt.unmarked)
+		if captures == nil {
+			t.Errorf("%s does not match %q", regex, tt.unmarked)
+			continue
+		}
+		for i, marked := range values {
+			if captures[i+1] != marked.value {
+				t.Errorf("%s captures %q instead of %q", regex, captures[i+1], marked.value)
+			}
+		}
+	}
+}
+
+func TestSuggestionCategories(t *testing.T) {
+	_, values, err := SuggestRegex("login {{user:jdoe}} from {{user:10.0.0.1}} as {{role:reviewer}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"user2": "user"}
+	if got := suggestionCategories(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestionCategories = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TermMatcher finds occurrences of many literal terms in a single pass over a
+// line using an Aho-Corasick automaton. Matching is case-insensitive under
+// Unicode simple case folding: terms and lines are folded before matching and
+// the offsets of the occurrences are mapped back to the original line.
+type TermMatcher struct {
+	wholeWord bool
+	edges     []map[byte]int
+	fail      []int
+	output    []int // length of the term ending in this state, 0 if none
+	dict      []int // nearest state on the fail chain with an output, -1 if none
+	terms     int
+	base      *TermMatcher // matcher whose terms are found in addition to these, see layered
+}
+
+type termMatch struct {
+	start, end int
+}
+
+// NewTermMatcher compiles terms into a TermMatcher.
+//
+// Parameters:
+//   - terms ([]string): The literal terms to search for; blank terms are ignored.
+//   - wholeWord (bool): Only match terms that are not part of a longer word.
+//
+// Returns:
+//   - *TermMatcher: The compiled matcher.
+func NewTermMatcher(terms []string, wholeWord bool) *TermMatcher {
+	m := &TermMatcher{wholeWord: wholeWord}
+	m.newState()
+
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		m.add(term)
+	}
+	m.build()
+
+	return m
+}
+
+// layered compiles terms into a TermMatcher that also finds the terms of base,
+// so terms can be added without recompiling the automaton of base. Occurrences
+// of both are resolved together as if all terms were in a single automaton.
+//
+// Parameters:
+//   - terms ([]string): The literal terms to search for in addition to base.
+//   - base (*TermMatcher): The matcher to extend, may be nil.
+//
+// Returns:
+//   - *TermMatcher: The compiled matcher, matching whole words if base does.
+func layered(terms []string, base *TermMatcher) *TermMatcher {
+	if base == nil {
+		return NewTermMatcher(terms, true)
+	}
+
+	m := NewTermMatcher(terms, base.wholeWord)
+	m.base = base
+	return m
+}
+
+// Len returns the number of terms in the matcher.
+func (m *TermMatcher) Len() int {
+	if m.base != nil {
+		return m.terms + m.base.Len()
+	}
+	return m.terms
+}
+
+func (m *TermMatcher) newState() int {
+	m.edges = append(m.edges, map[byte]int{})
+	m.fail = append(m.fail, 0)
+	m.output = append(m.output, 0)
+	m.dict = append(m.dict, -1)
+	return len(m.edges) - 1
+}
+
+func (m *TermMatcher) add(term string) {
+	term, _ = foldString(term)
+
+	state := 0
+	for i := 0; i < len(term); i++ {
+		b := term[i]
+		next, ok := m.edges[state][b]
+		if !ok {
+			next = m.newState()
+			m.edges[state][b] = next
+		}
+		state = next
+	}
+	if m.output[state] == 0 {
+		m.terms++
+	}
+	m.output[state] = len(term)
+}
+
+// build computes the fail and dictionary links breadth first.
+func (m *TermMatcher) build() {
+	queue := []int{}
+	for _, next := range m.edges[0] {
+		queue = append(queue, next)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for b, next := range m.edges[state] {
+			queue = append(queue, next)
+
+			f := m.fail[state]
+			for {
+				if target, ok := m.edges[f][b]; ok {
+					m.fail[next] = target
+					break
+				}
+				if f == 0 {
+					m.fail[next] = 0
+					break
+				}
+				f = m.fail[f]
+			}
+
+			if m.output[m.fail[next]] > 0 {
+				m.dict[next] = m.fail[next]
+			} else {
+				m.dict[next] = m.dict[m.fail[next]]
+			}
+		}
+	}
+}
+
+// FindAll returns the leftmost-longest, non-overlapping occurrences of the terms in line.
+//
+// Parameters:
+//   - line (string): The text to search.
+//
+// Returns:
+//   - []termMatch: The byte ranges of the occurrences in ascending order.
+func (m *TermMatcher) FindAll(line string) []termMatch {
+	if m == nil || m.Len() == 0 {
+		return nil
+	}
+
+	folded, offsets := foldString(line)
+
+	var candidates []termMatch
+	for layer := m; layer != nil; layer = layer.base {
+		candidates = append(candidates, layer.candidates(line, folded, offsets)...)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].start != candidates[j].start {
+			return candidates[i].start < candidates[j].start
+		}
+		return candidates[i].end > candidates[j].end
+	})
+
+	var matches []termMatch
+	last := 0
+	for _, c := range candidates {
+		if c.start >= last {
+			matches = append(matches, c)
+			last = c.end
+		}
+	}
+
+	return matches
+}
+
+// candidates returns every occurrence of the terms of this layer in line,
+// overlapping or not, in no particular order. Folded and offsets are the
+// result of foldString(line).
+func (m *TermMatcher) candidates(line, folded string, offsets []int) []termMatch {
+	if m.terms == 0 {
+		return nil
+	}
+
+	var candidates []termMatch
+	state := 0
+	for i := 0; i < len(folded); i++ {
+		b := folded[i]
+		for {
+			if next, ok := m.edges[state][b]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.fail[state]
+		}
+
+		for s := state; s > 0; s = m.dict[s] {
+			if length := m.output[s]; length > 0 {
+				candidates = append(candidates, termMatch{start: i + 1 - length, end: i + 1})
+			}
+		}
+	}
+
+	if offsets != nil {
+		for i, c := range candidates {
+			candidates[i] = termMatch{start: offsets[c.start], end: offsets[c.end]}
+		}
+	}
+
+	if m.wholeWord {
+		filtered := candidates[:0]
+		for _, c := range candidates {
+			if isWordBoundary(line, c.start) && isWordBoundary(line, c.end) {
+				filtered = append(filtered, c)
+			}
+		}
+		candidates = filtered
+	}
+
+	return candidates
+}
+
+// Replace substitutes every occurrence of the terms in line.
+//
+// Parameters:
+//   - line (string): The text to search.
+//   - replace (func(string) string): Returns the replacement for a matched occurrence.
+//
+// Returns:
+//   - string: The line with all occurrences replaced.
+//   - int: The number of replaced occurrences.
+func (m *TermMatcher) Replace(line string, replace func(string) string) (string, int) {
+	matches := m.FindAll(line)
+	if len(matches) == 0 {
+		return line, 0
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		sb.WriteString(line[last:match.start])
+		sb.WriteString(replace(line[match.start:match.end]))
+		last = match.end
+	}
+	sb.WriteString(line[last:])
+
+	return sb.String(), len(matches)
+}
+
+// foldRune returns the smallest rune of the simple case folding orbit of r, so
+// all case variants of a letter fold to the same rune, e.g. ü and Ü to Ü.
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < folded {
+			folded = f
+		}
+	}
+	return folded
+}
+
+// foldString folds every rune of s with foldRune. The folded form may differ in
+// length from s, so offsets maps each byte offset of the folded form to the
+// offset in s of the rune it belongs to, with one more entry for the end of s.
+// Offsets is nil for ASCII input, whose offsets are unchanged.
+func foldString(s string) (string, []int) {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		folded := []byte(s)
+		for i, b := range folded {
+			if b >= 'a' && b <= 'z' {
+				folded[i] = b - ('a' - 'A')
+			}
+		}
+		return string(folded), nil
+	}
+
+	var sb strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		before := sb.Len()
+		if r == utf8.RuneError {
+			// keep invalid bytes as they are
+			_, size := utf8.DecodeRuneInString(s[i:])
+			sb.WriteString(s[i : i+size])
+		} else {
+			sb.WriteRune(foldRune(r))
+		}
+		for j := before; j < sb.Len(); j++ {
+			offsets = append(offsets, i)
+		}
+	}
+	offsets = append(offsets, len(s))
+
+	return sb.String(), offsets
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b >= 0x80
+}
+
+// isWordBoundary reports whether position i in line is not inside a word.
+func isWordBoundary(line string, i int) bool {
+	if i == 0 || i == len(line) {
+		return true
+	}
+	return !isWordByte(line[i-1]) || !isWordByte(line[i])
+}
@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+func TestTermMatcherReplace(t *testing.T) {
+	tests := []struct {
+		name      string
+		terms     []string
+		wholeWord bool
+		line      string
+		want      string
+		count     int
+	}{
+		{"no terms", nil, false, "Acme Corp", "Acme Corp", 0},
+		{"blank terms ignored", []string{"", "  "}, false, "Acme Corp", "Acme Corp", 0},
+		{"single term", []string{"Acme"}, false, "sent to Acme today", "sent to [X] today", 1},
+		{"every occurrence", []string{"Acme"}, false, "Acme, Acme", "[X], [X]", 2},
+		{"case folding", []string{"ACME corp"}, false, "acme Corp and Acme CORP", "[X] and [X]", 2},
+		{"term is trimmed", []string{" Acme "}, false, "Acme", "[X]", 1},
+		{"Unicode case folding", []string{"Müller"}, false, "Müller MÜLLER müller", "[X] [X] [X]", 3},
+		{"folding changes the byte length", []string{"kelvin"}, false, "in \u212Aelvin", "in [X]", 1},
+		{"offsets after multi-byte runes", []string{"ÉCOLE"}, false, "à l’école x", "à l’[X] x", 1},
+		{"longest of overlapping terms at the same start", []string{"Acme", "Acme Corp"}, false, "Acme Corp", "[X]", 1},
+		{"leftmost of overlapping terms", []string{"Acme Corp", "Corp Ltd"}, false, "Acme Corp Ltd", "[X] Ltd", 1},
+		{"term inside another term", []string{"Acme Corp Ltd", "Corp"}, false, "Acme Corp Ltd and Corp", "[X] and [X]", 2},
+		{"suffix found through the fail links", []string{"abcd", "bc"}, false, "abce", "a[X]e", 1},
+		{"substring without wholeWord", []string{"ann"}, false, "Joanna", "Jo[X]a", 1},
+		{"substring with wholeWord", []string{"ann"}, true, "Joanna and ann", "Joanna and [X]", 1},
+		{"punctuation is a boundary", []string{"jdoe"}, true, "(jdoe), jdoe.txt", "([X]), [X].txt", 2},
+		{"underscore and digits are word bytes", []string{"jdoe"}, true, "jdoe_1 jdoe2 jdoe", "jdoe_1 jdoe2 [X]", 1},
+		{"non-ASCII is a word byte", []string{"ann"}, true, "ännann", "ännann", 0},
+		{"wholeWord falls back to a shorter term", []string{"Acme", "Acme Co"}, true, "Acme Corp", "[X] Corp", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewTermMatcher(tt.terms, tt.wholeWord)
+			got, count := m.Replace(tt.line, func(string) string { return "[X]" })
+			if got != tt.want || count != tt.count {
+				t.Errorf("Replace(%q) = %q, %d, want %q, %d", tt.line, got, count, tt.want, tt.count)
+			}
+		})
+	}
+}
+
+func TestTermMatcherReplacePassesOriginalText(t *testing.T) {
+	m := NewTermMatcher([]string{"acme"}, false)
+
+	var matched []string
+	m.Replace("ACME and Acme", func(s string) string {
+		matched = append(matched, s)
+		return s
+	})
+	if len(matched) != 2 || matched[0] != "ACME" || matched[1] != "Acme" {
+		t.Errorf("replace called with %q, want the original case", matched)
+	}
+}
+
+func TestTermMatcherNil(t *testing.T) {
+	var m *TermMatcher
+	if matches := m.FindAll("Acme"); matches != nil {
+		t.Errorf("nil matcher found %v", matches)
+	}
+}
+
+func TestLayeredTermMatcherResolvesLayersTogether(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  []string
+		terms []string
+		line  string
+		want  string
+	}{
+		{"terms of both layers", []string{"jdoe"}, []string{"asmith"}, "jdoe to asmith", "[X] to [X]"},
+		{"longer term in the new layer", []string{"jdoe"}, []string{"jdoe smith"}, "by jdoe smith", "by [X]"},
+		{"longer term in the base layer", []string{"jdoe smith"}, []string{"jdoe"}, "by jdoe smith", "by [X]"},
+		{"leftmost across layers", []string{"smith corp"}, []string{"jdoe smith"}, "jdoe smith corp", "[X] corp"},
+		{"no base", nil, []string{"jdoe"}, "jdoe", "[X]"},
+	}
+
+	for _, tt := range tests {
+		var base *TermMatcher
+		if tt.base != nil {
+			base = NewTermMatcher(tt.base, true)
+		}
+		m := layered(tt.terms, base)
+		if got, _ := m.Replace(tt.line, func(string) string { return "[X]" }); got != tt.want {
+			t.Errorf("%s: Replace(%q) = %q, want %q", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// parseTOML parses a TOML document into the YAML node tree the configuration is
+// decoded from, so references, unknown keys and line numbers are handled the
+// same way for every format. The document is validated by the go-toml decoder
+// and converted from the syntax tree of its parser, which keeps the order and
+// lines of the keys. Dates and times are kept as strings.
+//
+// Inputs:
+//   - data (string): The TOML document.
+//
+// Outputs:
+//   - *yaml.Node: A mapping node holding the document.
+//   - error: An error with the line of the first syntax error, or the key of
+//     the first key or table defined twice.
+func parseTOML(data string) (*yaml.Node, error) {
+	// the decoder rejects what the parser accepts, e.g. duplicate keys and redefined
+	// tables, these errors name the key instead of the line
+	var document map[string]any
+	if err := toml.Unmarshal([]byte(data), &document); err != nil {
+		message := strings.TrimPrefix(err.Error(), "toml: ")
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, _ := decodeErr.Position()
+			return nil, fmt.Errorf("line %d: %s", line, message)
+		}
+		return nil, errors.New(message)
+	}
+
+	b := &tomlBuilder{root: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1}}
+	b.current = b.root
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\n' {
+			b.newlines = append(b.newlines, i)
+		}
+	}
+
+	p := &unstable.Parser{}
+	p.Reset([]byte(data))
+	for p.NextExpression() {
+		b.expression(p.Expression())
+	}
+	if err := p.Error(); err != nil {
+		return nil, err
+	}
+
+	return b.root, nil
+}
+
+type tomlBuilder struct {
+	newlines []int // offsets of the newlines of the document
+	root     *yaml.Node
+	current  *yaml.Node // table key/value pairs are added to
+}
+
+// line returns the line a range of the document starts on.
+func (b *tomlBuilder) line(r unstable.Range) int {
+	return sort.SearchInts(b.newlines, int(r.Offset)) + 1
+}
+
+func (b *tomlBuilder) expression(expr *unstable.Node) {
+	switch expr.Kind {
+	case unstable.KeyValue:
+		b.keyValue(b.current, expr)
+	case unstable.Table:
+		b.current = b.root
+		for _, key := range tomlKeys(expr) {
+			b.current = b.table(b.current, key)
+		}
+	case unstable.ArrayTable:
+		keys := tomlKeys(expr)
+		table := b.root
+		for _, key := range keys[:len(keys)-1] {
+			table = b.table(table, key)
+		}
+
+		last := keys[len(keys)-1]
+		tables := tomlValue(table, string(last.Data))
+		if tables == nil {
+			tables = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: b.line(last.Raw)}
+			table.Content = append(table.Content, b.key(last), tables)
+		}
+		b.current = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: b.line(last.Raw)}
+		tables.Content = append(tables.Content, b.current)
+	}
+}
+
+// table returns the table key of parent, creating it if needed. For an array of
+// tables the last table is returned.
+func (b *tomlBuilder) table(parent *yaml.Node, key *unstable.Node) *yaml.Node {
+	child := tomlValue(parent, string(key.Data))
+	switch {
+	case child == nil:
+		child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: b.line(key.Raw)}
+		parent.Content = append(parent.Content, b.key(key), child)
+	case child.Kind == yaml.SequenceNode && len(child.Content) > 0:
+		child = child.Content[len(child.Content)-1]
+	}
+	return child
+}
+
+// keyValue adds a key/value pair, whose key may be dotted, to table.
+func (b *tomlBuilder) keyValue(table *yaml.Node, expr *unstable.Node) {
+	keys := tomlKeys(expr)
+	for _, key := range keys[:len(keys)-1] {
+		table = b.table(table, key)
+	}
+
+	last := keys[len(keys)-1]
+	table.Content = append(table.Content, b.key(last), b.value(expr.Value(), b.line(last.Raw)))
+}
+
+// value converts a value, line is used where the parser records no range.
+func (b *tomlBuilder) value(value *unstable.Node, line int) *yaml.Node {
+	if value.Raw.Length > 0 {
+		line = b.line(value.Raw)
+	}
+	scalar := func(tag, text string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: text, Line: line}
+	}
+
+	text := string(value.Data)
+	switch value.Kind {
+	case unstable.Bool:
+		return scalar("!!bool", text)
+	case unstable.Integer:
+		n, _ := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 0, 64)
+		return scalar("!!int", strconv.FormatInt(n, 10))
+	case unstable.Float:
+		return scalar("!!float", tomlFloat(strings.ReplaceAll(text, "_", "")))
+	case unstable.Array:
+		array := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for it := value.Children(); it.Next(); {
+			array.Content = append(array.Content, b.value(it.Node(), line))
+		}
+		return array
+	case unstable.InlineTable:
+		table := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		for it := value.Children(); it.Next(); {
+			b.keyValue(table, it.Node())
+		}
+		return table
+	default:
+		// strings, dates and times
+		return scalar("!!str", text)
+	}
+}
+
+func (b *tomlBuilder) key(key *unstable.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(key.Data), Line: b.line(key.Raw)}
+}
+
+// tomlKeys returns the parts of the key of a key/value pair or table header.
+func tomlKeys(expr *unstable.Node) []*unstable.Node {
+	var keys []*unstable.Node
+	for it := expr.Key(); it.Next(); {
+		keys = append(keys, it.Node())
+	}
+	return keys
+}
+
+// tomlFloat spells the special TOML floats the way YAML does.
+func tomlFloat(value string) string {
+	switch strings.TrimPrefix(value, "+") {
+	case "inf":
+		return ".inf"
+	case "-inf":
+		return "-.inf"
+	case "nan", "-nan":
+		return ".nan"
+	}
+	return value
+}
+
+// tomlValue returns the value of key in a mapping node, nil if absent.
+func tomlValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
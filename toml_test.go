@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeTOML(t *testing.T, data string) map[string]any {
+	t.Helper()
+
+	node, err := parseTOML(data)
+	if err != nil {
+		t.Fatalf("parseTOML(%q): %s", data, err)
+	}
+	var decoded map[string]any
+	if err := node.Decode(&decoded); err != nil {
+		t.Fatalf("decoding %q: %s", data, err)
+	}
+	return decoded
+}
+
+func TestParseTOMLStrings(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`s = "plain"`, "plain"},
+		{`s = "tab\tquote\"backslash\\"`, "tab\tquote\"backslash\\"},
+		{`s = "\u00e9\U0001F600"`, "é😀"},
+		{`s = "\b\f\r\n"`, "\b\f\r\n"},
+		{`s = 'C:\Users\(\d+)'`, `C:\Users\(\d+)`},
+		{`s = ''`, ""},
+		{"s = \"\"\"\nfirst\nsecond\"\"\"", "first\nsecond"},
+		{"s = \"\"\"\r\nfirst\"\"\"", "first"},
+		{"s = \"\"\"one \\\n    two\"\"\"", "one two"},
+		{"s = \"\"\"keep # this\"\"\"", "keep # this"},
+		{"s = \"\"\"one \\\n  # not a comment\"\"\"", "one # not a comment"},
+		{"s = '''\n^(.*)\\s'\n'''", "^(.*)\\s'\n"},
+		{`s = "a#b" # comment`, "a#b"},
+	}
+
+	for _, tt := range tests {
+		if got := decodeTOML(t, tt.data)["s"]; got != tt.want {
+			t.Errorf("parseTOML(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParseTOMLValues(t *testing.T) {
+	data := `
+# top level
+workers = 4
+ratio = 0.5
+big = 1_000
+strict = true
+kinds = ["engine", 'service',
+  "crawler", # trailing comma allowed
+]
+nested = [[1, 2], []]
+paths = { segments = ["user"], keep = { dirs = 1 } }
+a.b.c = "dotted"
+"quoted key" = 1
+hex = 0xff
+inf = -inf
+date = 1979-05-27
+`
+	want := map[string]any{
+		"workers":    4,
+		"ratio":      0.5,
+		"big":        1000,
+		"strict":     true,
+		"kinds":      []any{"engine", "service", "crawler"},
+		"nested":     []any{[]any{1, 2}, []any{}},
+		"paths":      map[string]any{"segments": []any{"user"}, "keep": map[string]any{"dirs": 1}},
+		"a":          map[string]any{"b": map[string]any{"c": "dotted"}},
+		"quoted key": 1,
+		"hex":        255,
+		"inf":        math.Inf(-1),
+		"date":       "1979-05-27",
+	}
+
+	if got := decodeTOML(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseTOMLTables(t *testing.T) {
+	data := `
+[defaults]
+obfuscation = "x"
+
+[[versions]]
+axcVersion = "default"
+
+[[versions.logs]]
+kind = "engine"
+
+[versions.logs.paths]
+segments = ["user"]
+
+[[versions.logs]]
+kind = "service"
+
+[[versions]]
+axcVersion = "22.0"
+`
+	want := map[string]any{
+		"defaults": map[string]any{"obfuscation": "x"},
+		"versions": []any{
+			map[string]any{
+				"axcVersion": "default",
+				"logs": []any{
+					map[string]any{"kind": "engine", "paths": map[string]any{"segments": []any{"user"}}},
+					map[string]any{"kind": "service"},
+				},
+			},
+			map[string]any{"axcVersion": "22.0"},
+		},
+	}
+
+	if got := decodeTOML(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseTOMLLines(t *testing.T) {
+	node, err := parseTOML("a = 1\ns = \"\"\"\n\nx\"\"\"\nb = 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := tomlValue(node, "b"); b == nil || b.Line != 5 {
+		t.Errorf("b is not reported on line 5: %+v", b)
+	}
+}
+
+func TestParseTOMLMalformed(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`s = "open`, `line 1: basic string not terminated by "`},
+		{"s = 'open\n'", "line 1: literal strings cannot have new lines"},
+		{"s = \"a\nb\"", "line 1: basic strings cannot have new lines"},
+		{`s = "\d"`, "line 1: invalid escaped character U+0064 'd'"},
+		{`s = "\u12"`, "line 1: unicode point needs 4 character"},
+		{`s = "\uD800"`, "line 1: escape sequence is invalid Unicode code point"},
+		{"\n\nkey value", "line 3: expected character ="},
+		{"a = [1, 2", "line 1: expected character ]"},
+		{"a = [1 2]", "line 1: array elements must be separated by commas"},
+		{"a = {b = 1", "line 1: expected character }"},
+		{"a = {b = 1 c = 2}", "line 1: expected character ,"},
+		{"a = 1 2", "line 1: expected newline but got U+0032 '2'"},
+		{"a =", "line 1: expected value"},
+		{"[table", "line 1: expected character ]"},
+		{"[[tables]", "line 1: expected character ]"},
+		{"\n= 1", "line 2: invalid character at start of key: ="},
+		{"a = 1\na = 2", "key a is already defined"},
+		{"[a]\n[a]", "table a already exists"},
+		{"a = 1\n[a]", "key a should be a table, not a value"},
+		{"[a]\n[[a]]", "but should be an array table"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseTOML(tt.data)
+		if err == nil {
+			t.Errorf("parseTOML(%q): expected an error", tt.data)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseTOML(%q) = %q, want %q", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestLoadConfigTOMLUnknownKeyLine(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{"config.toml": `
+[[anonymizer]]
+axcVersion = "default"
+
+[[anonymizer.logs]]
+kind = "engine"
+regexPatterns = ['user (\w+)']
+namingPattern = ["MindServer.*"]
+`})
+
+	_, err := LoadConfig(filepath.Join(dir, "config.toml"))
+	if err == nil || !strings.Contains(err.Error(), `config.toml:8: unknown key "namingPattern"`) {
+		t.Errorf("LoadConfig error = %v, want the unknown key on line 8", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version matching
+//
+// The axcVersion of a profile is matched against a requested version as
+//
+//   - an exact name, e.g. default
+//   - a version prefix, e.g. v22.0 matches 22.0 and v22.0.3 but not 22.1
+//   - a range of space separated constraints, e.g. >=22.0 <23
+//
+// The most specific matching profile wins: an exact name before any prefix,
+// longer prefixes before shorter ones and prefixes before ranges, ranges with
+// more constraints before ranges with fewer. Ties go to the profile defined first.
+
+const (
+	EXACT_MATCH_SCORE  = 1000
+	PREFIX_MATCH_SCORE = 100 // plus the number of prefix components
+	RANGE_MATCH_SCORE  = 10  // plus the number of constraints
+)
+
+// parseVersion splits a version like v22.0.3 into its numeric components.
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(version), "v"), "V")
+	if version == "" {
+		return nil, false
+	}
+
+	var components []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		components = append(components, n)
+	}
+
+	return components, true
+}
+
+// compareVersions compares two versions component-wise, missing components count as 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// matchVersion scores how specifically the axcVersion of a profile matches a requested version.
+//
+// Inputs:
+//   - profileVersion (string): The axcVersion of the profile, a name, prefix or range.
+//   - requested (string): The requested version.
+//
+// Outputs:
+//   - int: The score of the match, 0 if the profile does not match.
+func matchVersion(profileVersion, requested string) int {
+	if profileVersion == requested {
+		return EXACT_MATCH_SCORE
+	}
+
+	version, ok := parseVersion(requested)
+	if !ok {
+		return 0
+	}
+
+	if strings.ContainsAny(profileVersion, "<>=") {
+		constraints := strings.Fields(profileVersion)
+		for _, constraint := range constraints {
+			if !matchConstraint(constraint, version) {
+				return 0
+			}
+		}
+		return RANGE_MATCH_SCORE + len(constraints)
+	}
+
+	prefix, ok := parseVersion(profileVersion)
+	if !ok || len(prefix) > len(version) {
+		return 0
+	}
+	for i := range prefix {
+		if prefix[i] != version[i] {
+			return 0
+		}
+	}
+
+	return PREFIX_MATCH_SCORE + len(prefix)
+}
+
+// matchConstraint checks a single constraint like >=22.0 against a version.
+func matchConstraint(constraint string, version []int) bool {
+	operator := strings.TrimRight(constraint, "v0123456789.")
+	bound, ok := parseVersion(strings.TrimPrefix(constraint, operator))
+	if !ok {
+		return false
+	}
+
+	cmp := compareVersions(version, bound)
+	switch operator {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "=", "==":
+		return cmp == 0
+	}
+
+	return false
+}
+
+// findAxcVersion picks the axcVersion of the profile matching a requested version most specifically.
+//
+// Inputs:
+//   - requested (string): The requested version, e.g. v22.0.3.
+//
+// Outputs:
+//   - string: The axcVersion of the best matching profile.
+//   - error: An error listing the available profiles if none matches.
+func (cfg *AnonymizerConfiguration) findAxcVersion(requested string) (string, error) {
+	best, bestScore := "", 0
+	var available []string
+
+	for _, profile := range cfg.AnonymizerConfigs {
+		available = append(available, profile.AxcVersion)
+		if score := matchVersion(profile.AxcVersion, requested); score > bestScore {
+			best, bestScore = profile.AxcVersion, score
+		}
+	}
+
+	if bestScore == 0 {
+		return "", fmt.Errorf("no config found for version %s, available: %s", requested, strings.Join(available, ", "))
+	}
+
+	return best, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchVersion(t *testing.T) {
+	tests := []struct {
+		profile   string
+		requested string
+		want      int
+	}{
+		{"default", "default", EXACT_MATCH_SCORE},
+		{"v22.0", "v22.0", EXACT_MATCH_SCORE},
+		{"v22.0", "v22.0.3", PREFIX_MATCH_SCORE + 2},
+		{"v22.0", "22.0.3", PREFIX_MATCH_SCORE + 2},
+		{"v22", "v22.0.3", PREFIX_MATCH_SCORE + 1},
+		{"v22.0", "V22.0", PREFIX_MATCH_SCORE + 2},
+		{"v22.0.3", "v22.0", 0}, // a prefix longer than the version
+		{"v22.0", "v22.1", 0},
+		{"v22.0", "v220.0", 0},
+		{">=22.0 <23", "v22.0", RANGE_MATCH_SCORE + 2},
+		{">=22.0 <23", "v22.9.1", RANGE_MATCH_SCORE + 2},
+		{">=22.0 <23", "v22", RANGE_MATCH_SCORE + 2}, // missing components count as 0
+		{">=22.0 <23", "v23.0", 0},
+		{">=22.0 <23", "v21.9", 0},
+		{">22", "v22.0.1", RANGE_MATCH_SCORE + 1},
+		{">=22 ~23", "v22.0", 0}, // an unknown operator never matches
+		{"v22.0", "latest", 0},
+		{">=22.0", "latest", 0},
+		{"v22.0", "", 0},
+	}
+
+	for _, tt := range tests {
+		if got := matchVersion(tt.profile, tt.requested); got != tt.want {
+			t.Errorf("matchVersion(%q, %q) = %d, want %d", tt.profile, tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestMatchConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    []int
+		want       bool
+	}{
+		{">=22.0", []int{22}, true},
+		{">22.0", []int{22, 0, 0}, false},
+		{">22.0", []int{22, 0, 1}, true},
+		{"<=v22.1", []int{22, 1}, true},
+		{"<23", []int{22, 99}, true},
+		{"<23", []int{23}, false},
+		{"=22.0", []int{22}, true},
+		{"==22.0", []int{22, 1}, false},
+		{"22.0", []int{22, 0}, false}, // no operator
+		{">=x", []int{22}, false},     // unparseable bound
+	}
+
+	for _, tt := range tests {
+		if got := matchConstraint(tt.constraint, tt.version); got != tt.want {
+			t.Errorf("matchConstraint(%q, %v) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestFindAxcVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiles  []string
+		requested string
+		want      string // empty if no profile matches
+	}{
+		{"exact before prefix", []string{"v22", "v22.0"}, "v22.0", "v22.0"},
+		{"longer prefix first", []string{"v22", "v22.0", "v22.0.3"}, "v22.0.3", "v22.0.3"},
+		{"shorter prefix if the longer one does not match", []string{"v22", "v22.0"}, "v22.1", "v22"},
+		{"prefix before range", []string{">=22.0 <23", "v22"}, "v22.0.3", "v22"},
+		{"range if no prefix matches", []string{">=22.0 <23", "v21"}, "v22.0.3", ">=22.0 <23"},
+		{"more constraints first", []string{">=22", ">=22.0 <23"}, "v22.1", ">=22.0 <23"},
+		{"first of equally specific", []string{">=22", ">21"}, "v22", ">=22"},
+		{"exact name", []string{"default", "v22.0"}, "default", "default"},
+		{"no matching profile", []string{"default", "v22.0"}, "v23.1", ""},
+		{"unparseable request", []string{"default", "v22.0", ">=22"}, "latest", ""},
+	}
+
+	for _, tt := range tests {
+		cfg := &AnonymizerConfiguration{}
+		for _, profile := range tt.profiles {
+			cfg.AnonymizerConfigs = append(cfg.AnonymizerConfigs, AnonymizerConfig{AxcVersion: profile})
+		}
+
+		got, err := cfg.findAxcVersion(tt.requested)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: findAxcVersion(%q) = %q, want an error", tt.name, tt.requested, got)
+			} else if !strings.Contains(err.Error(), strings.Join(tt.profiles, ", ")) {
+				t.Errorf("%s: error %q does not list the available profiles", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: findAxcVersion(%q) = %q, %v, want %q", tt.name, tt.requested, got, err, tt.want)
+		}
+	}
+}